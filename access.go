@@ -0,0 +1,238 @@
+package telemux
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"syscall"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+func normalizeUsername(username string) string {
+	return strings.ToLower(strings.TrimPrefix(username, "@"))
+}
+
+// FromUsers filters updates sent by specific users.
+func FromUsers(ids ...int64) FilterFunc {
+	set := make(map[int64]bool)
+	for _, id := range ids {
+		set[id] = true
+	}
+	return func(u *Update) bool {
+		user := u.EffectiveUser()
+		return user != nil && set[user.ID]
+	}
+}
+
+// FromUsernames filters updates sent by users with specific usernames (case-insensitive, with or without "@").
+func FromUsernames(usernames ...string) FilterFunc {
+	set := make(map[string]bool)
+	for _, username := range usernames {
+		set[normalizeUsername(username)] = true
+	}
+	return func(u *Update) bool {
+		user := u.EffectiveUser()
+		return user != nil && user.UserName != "" && set[normalizeUsername(user.UserName)]
+	}
+}
+
+// InChats filters updates sent in specific chats.
+func InChats(ids ...int64) FilterFunc {
+	set := make(map[int64]bool)
+	for _, id := range ids {
+		set[id] = true
+	}
+	return func(u *Update) bool {
+		chat := u.EffectiveChat()
+		return chat != nil && set[chat.ID]
+	}
+}
+
+// AccessList is a thread-safe list of users, usernames & chats which can be changed at runtime.
+// It can be used as allowlist (see Allow) or denylist (see Deny), e. g. with Mux.SetGlobalFilter to gate whole subtrees.
+//
+// AccessList can be loaded from JSON file with the following structure:
+//
+//	{"users": [123, 456], "usernames": ["foo", "@bar"], "chats": [-100123]}
+//
+// and reloaded with Reload, ReloadOnSignal or a handler created with ReloadHandler.
+type AccessList struct {
+	Filename string
+
+	mutex     sync.RWMutex
+	users     map[int64]bool
+	usernames map[string]bool
+	chats     map[int64]bool
+}
+
+type accessListContent struct {
+	Users     []int64  `json:"users"`
+	Usernames []string `json:"usernames"`
+	Chats     []int64  `json:"chats"`
+}
+
+// NewAccessList creates an empty access list.
+func NewAccessList() *AccessList {
+	return &AccessList{
+		users:     make(map[int64]bool),
+		usernames: make(map[string]bool),
+		chats:     make(map[int64]bool),
+	}
+}
+
+// LoadAccessList creates an access list & loads it from file.
+func LoadAccessList(filename string) (*AccessList, error) {
+	l := NewAccessList()
+	l.Filename = filename
+	return l, l.Reload()
+}
+
+// Reload replaces contents of access list with contents of its file.
+func (l *AccessList) Reload() error {
+	data, err := ioutil.ReadFile(l.Filename)
+	if err != nil {
+		return err
+	}
+	var content accessListContent
+	if err := json.Unmarshal(data, &content); err != nil {
+		return fmt.Errorf("%s: %w", l.Filename, err)
+	}
+	users := make(map[int64]bool)
+	for _, id := range content.Users {
+		users[id] = true
+	}
+	usernames := make(map[string]bool)
+	for _, username := range content.Usernames {
+		usernames[normalizeUsername(username)] = true
+	}
+	chats := make(map[int64]bool)
+	for _, id := range content.Chats {
+		chats[id] = true
+	}
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	l.users, l.usernames, l.chats = users, usernames, chats
+	return nil
+}
+
+// ReloadOnSignal reloads access list every time the process receives one of signals (SIGHUP by default).
+// Reload errors are passed to onError, if it is not nil. Call returned function to stop listening for signals.
+func (l *AccessList) ReloadOnSignal(onError func(error), signals ...os.Signal) (stop func()) {
+	if len(signals) == 0 {
+		signals = []os.Signal{syscall.SIGHUP}
+	}
+	ch := make(chan os.Signal, 1)
+	done := make(chan struct{})
+	signal.Notify(ch, signals...)
+	go func() {
+		for {
+			select {
+			case <-ch:
+				if err := l.Reload(); err != nil && onError != nil {
+					onError(err)
+				}
+			case <-done:
+				return
+			}
+		}
+	}()
+	return func() {
+		signal.Stop(ch)
+		close(done)
+	}
+}
+
+// ReloadHandler creates a command handler which reloads access list and replies with the result.
+// Make sure to restrict it with filter, e. g. FromUsers(ADMIN_ID) or IsChatAdmin().
+func (l *AccessList) ReloadHandler(command string, filter FilterFunc) *Handler {
+	return NewCommandHandler(command, filter, func(u *Update) {
+		text := "Access list reloaded."
+		if err := l.Reload(); err != nil {
+			text = "Failed to reload access list: " + err.Error()
+		}
+		u.Bot.Send(tgbotapi.NewMessage(u.Message.Chat.ID, text))
+	})
+}
+
+// AddUsers adds users to access list.
+func (l *AccessList) AddUsers(ids ...int64) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	for _, id := range ids {
+		l.users[id] = true
+	}
+}
+
+// RemoveUsers removes users from access list.
+func (l *AccessList) RemoveUsers(ids ...int64) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	for _, id := range ids {
+		delete(l.users, id)
+	}
+}
+
+// AddUsernames adds usernames to access list.
+func (l *AccessList) AddUsernames(usernames ...string) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	for _, username := range usernames {
+		l.usernames[normalizeUsername(username)] = true
+	}
+}
+
+// RemoveUsernames removes usernames from access list.
+func (l *AccessList) RemoveUsernames(usernames ...string) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	for _, username := range usernames {
+		delete(l.usernames, normalizeUsername(username))
+	}
+}
+
+// AddChats adds chats to access list.
+func (l *AccessList) AddChats(ids ...int64) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	for _, id := range ids {
+		l.chats[id] = true
+	}
+}
+
+// RemoveChats removes chats from access list.
+func (l *AccessList) RemoveChats(ids ...int64) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	for _, id := range ids {
+		delete(l.chats, id)
+	}
+}
+
+// Contains checks if sender or chat of the update is in access list.
+func (l *AccessList) Contains(u *Update) bool {
+	l.mutex.RLock()
+	defer l.mutex.RUnlock()
+	if chat := u.EffectiveChat(); chat != nil && l.chats[chat.ID] {
+		return true
+	}
+	user := u.EffectiveUser()
+	if user == nil {
+		return false
+	}
+	return l.users[user.ID] || (user.UserName != "" && l.usernames[normalizeUsername(user.UserName)])
+}
+
+// Allow filters updates whose sender or chat is in access list.
+func (l *AccessList) Allow() FilterFunc {
+	return l.Contains
+}
+
+// Deny filters updates whose sender and chat are not in access list.
+func (l *AccessList) Deny() FilterFunc {
+	return Not(l.Contains)
+}
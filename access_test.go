@@ -0,0 +1,65 @@
+package telemux_test
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	tm "github.com/and3rson/telemux/v2"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+func newAccessUpdate(userID int64, username string, chatID int64) *tm.Update {
+	u := &tm.Update{}
+	u.Message = &tgbotapi.Message{
+		From: &tgbotapi.User{ID: userID, UserName: username},
+		Chat: &tgbotapi.Chat{ID: chatID},
+	}
+	return u
+}
+
+func TestStaticAccessFilters(t *testing.T) {
+	assert(tm.FromUsers(1, 2)(newAccessUpdate(2, "", 10)), t)
+	assert(!tm.FromUsers(1, 2)(newAccessUpdate(3, "", 10)), t)
+	assert(tm.FromUsernames("@Foo", "bar")(newAccessUpdate(3, "foo", 10)), t)
+	assert(!tm.FromUsernames("@Foo", "bar")(newAccessUpdate(3, "", 10)), t)
+	assert(tm.InChats(10)(newAccessUpdate(3, "", 10)), t)
+	assert(!tm.InChats(10)(newAccessUpdate(3, "", 11)), t)
+	assert(!tm.InChats(10)(&tm.Update{}), t)
+}
+
+func TestAccessList(t *testing.T) {
+	f, err := ioutil.TempFile("", "telemux_access")
+	if err != nil {
+		t.Error("Failed to create temporary file")
+	}
+	defer os.Remove(f.Name())
+	f.WriteString(`{"users": [1], "usernames": ["@Foo"], "chats": [-100]}`)
+	f.Close()
+
+	l, err := tm.LoadAccessList(f.Name())
+	assert(err == nil, t, err)
+	assert(l.Allow()(newAccessUpdate(1, "", 10)), t)
+	assert(l.Allow()(newAccessUpdate(2, "FOO", 10)), t)
+	assert(l.Allow()(newAccessUpdate(2, "", -100)), t)
+	assert(!l.Allow()(newAccessUpdate(2, "", 10)), t)
+	assert(l.Deny()(newAccessUpdate(2, "", 10)), t)
+	assert(!l.Deny()(newAccessUpdate(1, "", 10)), t)
+
+	l.AddUsers(2)
+	l.RemoveUsernames("foo")
+	assert(l.Allow()(newAccessUpdate(2, "", 10)), t)
+	assert(!l.Allow()(newAccessUpdate(3, "foo", 10)), t)
+
+	ioutil.WriteFile(f.Name(), []byte(`{"users": [3]}`), 0644)
+	assert(l.Reload() == nil, t)
+	assert(l.Allow()(newAccessUpdate(3, "", 10)), t)
+	assert(!l.Allow()(newAccessUpdate(1, "", -100)), t)
+
+	ioutil.WriteFile(f.Name(), []byte(`{"users": [`), 0644)
+	assert(l.Reload() != nil, t)
+	assert(l.Allow()(newAccessUpdate(3, "", 10)), t)
+
+	_, err = tm.LoadAccessList(f.Name() + ".missing")
+	assert(err != nil, t)
+}
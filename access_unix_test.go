@@ -0,0 +1,37 @@
+//go:build !windows
+// +build !windows
+
+package telemux_test
+
+import (
+	"io/ioutil"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+
+	tm "github.com/and3rson/telemux/v2"
+)
+
+func TestAccessListReloadOnSignal(t *testing.T) {
+	f, err := ioutil.TempFile("", "telemux_access")
+	if err != nil {
+		t.Error("Failed to create temporary file")
+	}
+	defer os.Remove(f.Name())
+	f.WriteString(`{"users": [1]}`)
+	f.Close()
+
+	l, _ := tm.LoadAccessList(f.Name())
+	stop := l.ReloadOnSignal(nil, syscall.SIGUSR1)
+	defer stop()
+
+	ioutil.WriteFile(f.Name(), []byte(`{"users": [3]}`), 0644)
+	syscall.Kill(os.Getpid(), syscall.SIGUSR1)
+	deadline := time.Now().Add(time.Second)
+	for !l.Allow()(newAccessUpdate(3, "", 10)) && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	assert(l.Allow()(newAccessUpdate(3, "", 10)), t)
+	assert(!l.Allow()(newAccessUpdate(1, "", 10)), t)
+}
@@ -0,0 +1,293 @@
+package telemux
+
+import (
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// ChatPermission is a name of administrator right as defined by Telegram Bot API, e. g. "can_delete_messages".
+type ChatPermission string
+
+// Administrator rights which can be checked with HasChatPermission & BotHasPermission.
+const (
+	CanManageChat       ChatPermission = "can_manage_chat"
+	CanPostMessages     ChatPermission = "can_post_messages"
+	CanEditMessages     ChatPermission = "can_edit_messages"
+	CanDeleteMessages   ChatPermission = "can_delete_messages"
+	CanManageVoiceChats ChatPermission = "can_manage_voice_chats"
+	CanRestrictMembers  ChatPermission = "can_restrict_members"
+	CanPromoteMembers   ChatPermission = "can_promote_members"
+	CanChangeInfo       ChatPermission = "can_change_info"
+	CanInviteUsers      ChatPermission = "can_invite_users"
+	CanPinMessages      ChatPermission = "can_pin_messages"
+)
+
+// HasPermission checks if chat member has an administrator right. Chat creator has all rights.
+func HasPermission(member tgbotapi.ChatMember, perm ChatPermission) bool {
+	if member.IsCreator() {
+		return true
+	}
+	if !member.IsAdministrator() {
+		return false
+	}
+	switch perm {
+	case CanManageChat:
+		return member.CanManageChat
+	case CanPostMessages:
+		return member.CanPostMessages
+	case CanEditMessages:
+		return member.CanEditMessages
+	case CanDeleteMessages:
+		return member.CanDeleteMessages
+	case CanManageVoiceChats:
+		return member.CanManageVoiceChats
+	case CanRestrictMembers:
+		return member.CanRestrictMembers
+	case CanPromoteMembers:
+		return member.CanPromoteMembers
+	case CanChangeInfo:
+		return member.CanChangeInfo
+	case CanInviteUsers:
+		return member.CanInviteUsers
+	case CanPinMessages:
+		return member.CanPinMessages
+	}
+	return false
+}
+
+// AdminFetchFunc retrieves a list of chat administrators.
+type AdminFetchFunc func(bot *tgbotapi.BotAPI, chatID int64) ([]tgbotapi.ChatMember, error)
+
+type adminCacheEntry struct {
+	members []tgbotapi.ChatMember
+	err     error
+	expires time.Time
+}
+
+type adminFetch struct {
+	done    chan struct{}
+	members []tgbotapi.ChatMember
+	err     error
+}
+
+// AdminCache keeps lists of chat administrators to avoid calling getChatAdministrators for every update.
+// Lists expire after TTL and are also invalidated when an update about chat member changes is observed.
+// Failed fetches are cached for ErrorTTL, so a chat where getChatAdministrators fails does not hit the API on every update.
+// Only one fetch per chat is performed at a time, other callers wait for its result.
+//
+// Messages sent by anonymous administrators on behalf of the chat are treated as sent by an administrator
+// without any known rights, since Telegram does not tell which administrator sent them.
+// Use SetTrustAnonymousAdmins to treat them as sent by the chat creator.
+//
+// DefaultAdminCache is used by IsChatAdmin, IsChatCreator, HasChatPermission & BotHasPermission filters
+// and is invalidated automatically by Mux.Dispatch.
+// Keep in mind that Telegram delivers "chat_member" updates only if they are listed in allowed_updates.
+type AdminCache struct {
+	TTL                  time.Duration
+	ErrorTTL             time.Duration
+	Fetch                AdminFetchFunc
+	TrustAnonymousAdmins bool
+
+	mutex    sync.Mutex
+	entries  map[int64]adminCacheEntry
+	fetching map[int64]*adminFetch
+}
+
+// DefaultAdminCache is used by admin filters. Administrators are cached for 5 minutes.
+var DefaultAdminCache = NewAdminCache(5 * time.Minute)
+
+// NewAdminCache creates new instance of AdminCache which fetches administrators with Bot API.
+// Failed fetches are cached for 30 seconds or ttl, whichever is shorter.
+func NewAdminCache(ttl time.Duration) *AdminCache {
+	errorTTL := 30 * time.Second
+	if ttl < errorTTL {
+		errorTTL = ttl
+	}
+	return &AdminCache{
+		TTL:      ttl,
+		ErrorTTL: errorTTL,
+		Fetch: func(bot *tgbotapi.BotAPI, chatID int64) ([]tgbotapi.ChatMember, error) {
+			return bot.GetChatAdministrators(tgbotapi.ChatAdministratorsConfig{
+				ChatConfig: tgbotapi.ChatConfig{ChatID: chatID},
+			})
+		},
+		entries:  make(map[int64]adminCacheEntry),
+		fetching: make(map[int64]*adminFetch),
+	}
+}
+
+// SetTrustAnonymousAdmins tells whether messages sent by anonymous administrators on behalf of the chat
+// should be treated as sent by the chat creator, i. e. pass IsChatCreator & HasChatPermission filters.
+// Keep in mind that any administrator can send such messages regardless of their rights.
+// This function returns the receiver for convenient chaining.
+func (c *AdminCache) SetTrustAnonymousAdmins(trust bool) *AdminCache {
+	c.TrustAnonymousAdmins = trust
+	return c
+}
+
+// Administrators returns cached list of chat administrators, fetching it if necessary.
+func (c *AdminCache) Administrators(bot *tgbotapi.BotAPI, chatID int64) ([]tgbotapi.ChatMember, error) {
+	c.mutex.Lock()
+	if entry, ok := c.entries[chatID]; ok && time.Now().Before(entry.expires) {
+		c.mutex.Unlock()
+		return entry.members, entry.err
+	}
+	if call, ok := c.fetching[chatID]; ok {
+		c.mutex.Unlock()
+		<-call.done
+		return call.members, call.err
+	}
+	call := &adminFetch{done: make(chan struct{})}
+	c.fetching[chatID] = call
+	c.mutex.Unlock()
+
+	fetched := false
+	defer func() {
+		if !fetched {
+			// Fetch panicked: release waiting checks & fetch again next time
+			call.err = fmt.Errorf("failed to fetch administrators of chat %d", chatID)
+			c.mutex.Lock()
+			if c.fetching[chatID] == call {
+				delete(c.fetching, chatID)
+			}
+			c.mutex.Unlock()
+		}
+		close(call.done)
+	}()
+	call.members, call.err = c.Fetch(bot, chatID)
+	fetched = true
+	ttl := c.TTL
+	if call.err != nil {
+		log.Printf("Failed to fetch administrators of chat %d: %s", chatID, call.err)
+		ttl = c.ErrorTTL
+	}
+
+	c.mutex.Lock()
+	// Fetch is not cached if chat was invalidated meanwhile
+	if c.fetching[chatID] == call {
+		delete(c.fetching, chatID)
+		c.entries[chatID] = adminCacheEntry{call.members, call.err, time.Now().Add(ttl)}
+	}
+	c.mutex.Unlock()
+	return call.members, call.err
+}
+
+// Administrator returns chat member if user is administrator or creator of the chat.
+func (c *AdminCache) Administrator(bot *tgbotapi.BotAPI, chatID int64, userID int64) (tgbotapi.ChatMember, bool) {
+	members, err := c.Administrators(bot, chatID)
+	if err != nil {
+		return tgbotapi.ChatMember{}, false
+	}
+	for _, member := range members {
+		if member.User != nil && member.User.ID == userID {
+			return member, true
+		}
+	}
+	return tgbotapi.ChatMember{}, false
+}
+
+// Invalidate removes cached administrators of a chat.
+func (c *AdminCache) Invalidate(chatID int64) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	delete(c.entries, chatID)
+	delete(c.fetching, chatID)
+}
+
+// Observe invalidates cached administrators if update describes changes of chat members.
+func (c *AdminCache) Observe(u *Update) {
+	if u.ChatMember != nil {
+		c.Invalidate(u.ChatMember.Chat.ID)
+	}
+	if u.MyChatMember != nil {
+		c.Invalidate(u.MyChatMember.Chat.ID)
+	}
+	if message := u.EffectiveMessage(); message != nil && message.Chat != nil && message.LeftChatMember != nil {
+		c.Invalidate(message.Chat.ID)
+	}
+}
+
+// senderAdmin returns chat member of update sender if sender is chat administrator.
+// Messages sent by anonymous administrators on behalf of the chat are treated as sent by an administrator without rights
+// (or by the chat creator if TrustAnonymousAdmins is set).
+func (c *AdminCache) senderAdmin(u *Update) (tgbotapi.ChatMember, bool) {
+	chat := u.EffectiveChat()
+	if chat == nil || chat.IsPrivate() {
+		return tgbotapi.ChatMember{}, false
+	}
+	if message := u.EffectiveMessage(); u.CallbackQuery == nil && message != nil && message.SenderChat != nil && message.SenderChat.ID == chat.ID {
+		if c.TrustAnonymousAdmins {
+			return tgbotapi.ChatMember{Status: "creator", IsAnonymous: true}, true
+		}
+		return tgbotapi.ChatMember{Status: "administrator", IsAnonymous: true}, true
+	}
+	user := u.EffectiveUser()
+	if user == nil || u.Bot == nil {
+		return tgbotapi.ChatMember{}, false
+	}
+	return c.Administrator(u.Bot, chat.ID, user.ID)
+}
+
+// IsChatAdmin filters updates sent by administrators (or creator) of the chat.
+func (c *AdminCache) IsChatAdmin() FilterFunc {
+	return func(u *Update) bool {
+		_, ok := c.senderAdmin(u)
+		return ok
+	}
+}
+
+// IsChatCreator filters updates sent by creator of the chat.
+func (c *AdminCache) IsChatCreator() FilterFunc {
+	return func(u *Update) bool {
+		member, ok := c.senderAdmin(u)
+		return ok && member.IsCreator()
+	}
+}
+
+// HasChatPermission filters updates sent by chat administrators who have specific right.
+func (c *AdminCache) HasChatPermission(perm ChatPermission) FilterFunc {
+	return func(u *Update) bool {
+		member, ok := c.senderAdmin(u)
+		return ok && HasPermission(member, perm)
+	}
+}
+
+// BotHasPermission filters updates from chats where the bot is administrator with specific right.
+func (c *AdminCache) BotHasPermission(perm ChatPermission) FilterFunc {
+	return func(u *Update) bool {
+		chat := u.EffectiveChat()
+		if chat == nil || chat.IsPrivate() || u.Bot == nil {
+			return false
+		}
+		member, ok := c.Administrator(u.Bot, chat.ID, u.Bot.Self.ID)
+		return ok && HasPermission(member, perm)
+	}
+}
+
+// IsChatAdmin filters updates sent by administrators (or creator) of the chat.
+// Administrators are cached in DefaultAdminCache.
+func IsChatAdmin() FilterFunc {
+	return DefaultAdminCache.IsChatAdmin()
+}
+
+// IsChatCreator filters updates sent by creator of the chat.
+// Administrators are cached in DefaultAdminCache.
+func IsChatCreator() FilterFunc {
+	return DefaultAdminCache.IsChatCreator()
+}
+
+// HasChatPermission filters updates sent by chat administrators who have specific right,
+// e. g. HasChatPermission(CanRestrictMembers). Administrators are cached in DefaultAdminCache.
+func HasChatPermission(perm ChatPermission) FilterFunc {
+	return DefaultAdminCache.HasChatPermission(perm)
+}
+
+// BotHasPermission filters updates from chats where the bot is administrator with specific right,
+// e. g. BotHasPermission(CanDeleteMessages). Administrators are cached in DefaultAdminCache.
+func BotHasPermission(perm ChatPermission) FilterFunc {
+	return DefaultAdminCache.BotHasPermission(perm)
+}
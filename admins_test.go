@@ -0,0 +1,162 @@
+package telemux_test
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	tm "github.com/and3rson/telemux/v2"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+func TestAdminFilters(t *testing.T) {
+	fetches := 0
+	defaultFetch := tm.DefaultAdminCache.Fetch
+	defer func() { tm.DefaultAdminCache.Fetch = defaultFetch }()
+	tm.DefaultAdminCache.Fetch = func(bot *tgbotapi.BotAPI, chatID int64) ([]tgbotapi.ChatMember, error) {
+		fetches++
+		if chatID != -100 {
+			return nil, errors.New("chat not found")
+		}
+		return []tgbotapi.ChatMember{
+			{User: &tgbotapi.User{ID: 1}, Status: "creator"},
+			{User: &tgbotapi.User{ID: 2}, Status: "administrator", CanDeleteMessages: true},
+			{User: &tgbotapi.User{ID: 42}, Status: "administrator", CanRestrictMembers: true},
+		}, nil
+	}
+	NewUpdate := func(userID int64, chatID int64) *tm.Update {
+		u := &tm.Update{}
+		u.Message = &tgbotapi.Message{
+			From: &tgbotapi.User{ID: userID},
+			Chat: &tgbotapi.Chat{ID: chatID, Type: "supergroup"},
+		}
+		u.Bot = &tgbotapi.BotAPI{}
+		u.Bot.Self.ID = 42
+		return u
+	}
+
+	assert(tm.IsChatAdmin()(NewUpdate(1, -100)), t)
+	assert(tm.IsChatCreator()(NewUpdate(1, -100)), t)
+	assert(tm.IsChatAdmin()(NewUpdate(2, -100)), t)
+	assert(!tm.IsChatCreator()(NewUpdate(2, -100)), t)
+	assert(!tm.IsChatAdmin()(NewUpdate(3, -100)), t)
+	assert(tm.HasChatPermission(tm.CanDeleteMessages)(NewUpdate(1, -100)), t)
+	assert(tm.HasChatPermission(tm.CanDeleteMessages)(NewUpdate(2, -100)), t)
+	assert(!tm.HasChatPermission(tm.CanPinMessages)(NewUpdate(2, -100)), t)
+	assert(tm.BotHasPermission(tm.CanRestrictMembers)(NewUpdate(3, -100)), t)
+	assert(!tm.BotHasPermission(tm.CanDeleteMessages)(NewUpdate(3, -100)), t)
+	assert(fetches == 1, t, fetches)
+
+	assert(!tm.IsChatAdmin()(NewUpdate(1, -200)), t)
+	u := NewUpdate(1, 1)
+	u.Message.Chat.Type = "private"
+	assert(!tm.IsChatAdmin()(u), t)
+
+	// Anonymous administrator
+	u = NewUpdate(1087968824, -100)
+	u.Message.SenderChat = &tgbotapi.Chat{ID: -100}
+	assert(tm.IsChatAdmin()(u), t)
+	assert(!tm.IsChatCreator()(u), t)
+	assert(!tm.HasChatPermission(tm.CanPromoteMembers)(u), t)
+	trusting := tm.NewAdminCache(time.Minute).SetTrustAnonymousAdmins(true)
+	assert(trusting.IsChatCreator()(u), t)
+	assert(trusting.HasChatPermission(tm.CanPromoteMembers)(u), t)
+
+	// Updates without bot are not checked
+	u = NewUpdate(1, -100)
+	u.Bot = nil
+	assert(!tm.IsChatAdmin()(u), t)
+
+	// chat_member updates invalidate cache
+	update := tgbotapi.Update{ChatMember: &tgbotapi.ChatMemberUpdated{Chat: tgbotapi.Chat{ID: -100}}}
+	tm.NewMux().Dispatch(nil, update)
+	tm.IsChatAdmin()(NewUpdate(1, -100))
+	assert(fetches == 3, t, fetches)
+
+	cache := tm.NewAdminCache(time.Nanosecond)
+	cache.Fetch = tm.DefaultAdminCache.Fetch
+	cache.IsChatAdmin()(NewUpdate(1, -100))
+	time.Sleep(time.Millisecond)
+	cache.IsChatAdmin()(NewUpdate(1, -100))
+	assert(fetches == 5, t, fetches)
+}
+
+func TestAdminCacheFetch(t *testing.T) {
+	var mutex sync.Mutex
+	fetches := 0
+	release := make(chan bool)
+	cache := tm.NewAdminCache(time.Minute)
+	cache.Fetch = func(bot *tgbotapi.BotAPI, chatID int64) ([]tgbotapi.ChatMember, error) {
+		mutex.Lock()
+		fetches++
+		mutex.Unlock()
+		if chatID != -100 {
+			return nil, errors.New("chat not found")
+		}
+		<-release
+		return []tgbotapi.ChatMember{{User: &tgbotapi.User{ID: 1}, Status: "creator"}}, nil
+	}
+	bot := &tgbotapi.BotAPI{}
+
+	// Concurrent callers share a single fetch, other chats are not blocked by it
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, ok := cache.Administrator(bot, -100, 1)
+			assert(ok, t)
+		}()
+	}
+	_, err := cache.Administrators(bot, -200)
+	assert(err != nil, t)
+	close(release)
+	wg.Wait()
+	mutex.Lock()
+	assert(fetches == 2, t, fetches)
+	mutex.Unlock()
+
+	// Errors are cached for ErrorTTL
+	_, err = cache.Administrators(bot, -200)
+	assert(err != nil, t)
+	assert(fetches == 2, t, fetches)
+	cache.ErrorTTL = time.Nanosecond
+	cache.Invalidate(-200)
+	cache.Administrators(bot, -200)
+	time.Sleep(time.Millisecond)
+	cache.Administrators(bot, -200)
+	assert(fetches == 4, t, fetches)
+
+	// Panic in Fetch releases waiting callers & does not block later checks
+	started := make(chan bool)
+	explode := make(chan bool)
+	cache.Fetch = func(bot *tgbotapi.BotAPI, chatID int64) ([]tgbotapi.ChatMember, error) {
+		close(started)
+		<-explode
+		panic("boom")
+	}
+	go func() {
+		defer func() { recover() }()
+		cache.Administrators(bot, -300)
+	}()
+	<-started
+	waited := make(chan error)
+	go func() {
+		_, err := cache.Administrators(bot, -300)
+		waited <- err
+	}()
+	time.Sleep(10 * time.Millisecond)
+	close(explode)
+	select {
+	case err := <-waited:
+		assert(err != nil, t)
+	case <-time.After(time.Second):
+		t.Fatal("waiting caller was not released")
+	}
+	cache.Fetch = func(bot *tgbotapi.BotAPI, chatID int64) ([]tgbotapi.ChatMember, error) {
+		return []tgbotapi.ChatMember{{User: &tgbotapi.User{ID: 1}, Status: "creator"}}, nil
+	}
+	_, ok := cache.Administrator(bot, -300, 1)
+	assert(ok, t)
+}
@@ -0,0 +1,54 @@
+package telemux
+
+import (
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// messageTime returns time of the last change of message: edit date for edited messages, send date otherwise.
+// Callback queries are not considered, since their message can be arbitrarily old.
+func messageTime(u *Update) (time.Time, bool) {
+	candidates := []*tgbotapi.Message{u.Message, u.EditedMessage, u.ChannelPost, u.EditedChannelPost}
+	for _, message := range candidates {
+		if message == nil {
+			continue
+		}
+		if message.EditDate != 0 {
+			return time.Unix(int64(message.EditDate), 0), true
+		}
+		if message.Date != 0 {
+			return message.Time(), true
+		}
+		return time.Time{}, false
+	}
+	return time.Time{}, false
+}
+
+// NewerThan filters updates with message sent (or edited) less than d ago.
+// Updates without message date (e. g. callback queries) are rejected.
+func NewerThan(d time.Duration) FilterFunc {
+	return func(u *Update) bool {
+		date, ok := messageTime(u)
+		return ok && time.Since(date) < d
+	}
+}
+
+// OlderThan filters updates with message sent (or edited) more than d ago,
+// e. g. commands received by the bot while it was offline.
+// Updates without message date (e. g. callback queries) are rejected.
+func OlderThan(d time.Duration) FilterFunc {
+	return func(u *Update) bool {
+		date, ok := messageTime(u)
+		return ok && time.Since(date) >= d
+	}
+}
+
+// EditedWithin filters updates with message which was edited less than d ago.
+func EditedWithin(d time.Duration) FilterFunc {
+	return func(u *Update) bool {
+		message := u.EffectiveMessage()
+		return u.CallbackQuery == nil && message != nil && message.EditDate != 0 &&
+			time.Since(time.Unix(int64(message.EditDate), 0)) < d
+	}
+}
@@ -0,0 +1,69 @@
+package telemux_test
+
+import (
+	"testing"
+	"time"
+
+	tm "github.com/and3rson/telemux/v2"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+func TestAgeFilters(t *testing.T) {
+	now := time.Now()
+	u := &tm.Update{}
+	assert(!tm.NewerThan(time.Hour)(u), t)
+	assert(!tm.OlderThan(time.Hour)(u), t)
+
+	u.Message = &tgbotapi.Message{Date: int(now.Add(-2 * time.Hour).Unix())}
+	assert(!tm.NewerThan(time.Hour)(u), t)
+	assert(tm.NewerThan(3*time.Hour)(u), t)
+	assert(tm.OlderThan(time.Hour)(u), t)
+	assert(!tm.OlderThan(3*time.Hour)(u), t)
+	assert(!tm.EditedWithin(time.Hour)(u), t)
+
+	u.Message, u.EditedMessage = nil, u.Message
+	u.EditedMessage.EditDate = int(now.Add(-time.Minute).Unix())
+	assert(tm.NewerThan(time.Hour)(u), t)
+	assert(!tm.OlderThan(time.Hour)(u), t)
+	assert(tm.EditedWithin(time.Hour)(u), t)
+	assert(!tm.EditedWithin(time.Second)(u), t)
+
+	// Callback queries have no date, their messages can be arbitrarily old
+	u = &tm.Update{}
+	u.CallbackQuery = &tgbotapi.CallbackQuery{Message: &tgbotapi.Message{Date: 1, EditDate: int(now.Unix())}}
+	assert(!tm.NewerThan(time.Hour)(u), t)
+	assert(!tm.OlderThan(time.Hour)(u), t)
+	assert(!tm.EditedWithin(time.Hour)(u), t)
+}
+
+func TestMuxMaxAge(t *testing.T) {
+	NewTGUpdate := func(age time.Duration) tgbotapi.Update {
+		u := tgbotapi.Update{}
+		u.Message = &tgbotapi.Message{Text: "/start", Date: int(time.Now().Add(-age).Unix()), Chat: &tgbotapi.Chat{}}
+		return u
+	}
+
+	handled, stale := 0, 0
+	mux := tm.NewMux().
+		SetMaxAge(time.Minute, nil).
+		AddHandler(tm.NewHandler(tm.Any(), func(u *tm.Update) { handled++ }))
+	assert(mux.Dispatch(nil, NewTGUpdate(time.Second)), t)
+	assert(!mux.Dispatch(nil, NewTGUpdate(time.Hour)), t)
+	assert(mux.Dispatch(nil, tgbotapi.Update{CallbackQuery: &tgbotapi.CallbackQuery{}}), t)
+	assert(handled == 2, t, handled)
+
+	mux.SetMaxAge(time.Minute, tm.NewHandler(tm.Any(), func(u *tm.Update) { stale++ }))
+	assert(mux.Dispatch(nil, NewTGUpdate(time.Hour)), t)
+	assert(mux.Dispatch(nil, NewTGUpdate(time.Second)), t)
+	assert(handled == 3 && stale == 1, t, handled, stale)
+
+	mux.SetMaxAge(0, nil)
+	assert(mux.Dispatch(nil, NewTGUpdate(time.Hour)), t)
+	assert(handled == 4, t, handled)
+
+	// Global filter is checked before staleness
+	mux.SetMaxAge(time.Minute, tm.NewHandler(tm.Any(), func(u *tm.Update) { stale++ })).
+		SetGlobalFilter(tm.Not(tm.Any()))
+	assert(!mux.Dispatch(nil, NewTGUpdate(time.Hour)), t)
+	assert(stale == 1, t, stale)
+}
@@ -0,0 +1,495 @@
+package telemux
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+type rawArg struct {
+	value  string
+	quoted bool
+	// start is a byte offset of the argument in text
+	start int
+}
+
+var doubleQuoteUnescaper = strings.NewReplacer(`\\`, `\`, `\"`, `"`)
+
+// closingQuote returns index of the quote which closes the quote at start, or -1 if there is none.
+// Quote is closing only if it is followed by whitespace or end of text, so apostrophes within words are kept.
+func closingQuote(text string, start int) int {
+	quote := text[start]
+	for i := start + 1; i < len(text); i++ {
+		switch {
+		case quote == '"' && text[i] == '\\' && i+1 < len(text):
+			i++
+		case text[i] == quote:
+			if next, _ := utf8.DecodeRuneInString(text[i+1:]); i+1 == len(text) || unicode.IsSpace(next) {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// splitArgs splits text into arguments. Spans are byte ranges of text which are taken as single quoted arguments
+// if an argument starts with them, e. g. text mentions (see mentionSpans).
+func splitArgs(text string, spans [][2]int) []rawArg {
+	args := []rawArg{}
+	for i := 0; i < len(text); {
+		r, size := utf8.DecodeRuneInString(text[i:])
+		if unicode.IsSpace(r) {
+			i += size
+			continue
+		}
+		if end := spanEnd(spans, i); end != -1 {
+			args = append(args, rawArg{text[i:end], true, i})
+			i = end
+			continue
+		}
+		if r == '"' || r == '\'' {
+			if end := closingQuote(text, i); end != -1 {
+				value := text[i+1 : end]
+				if r == '"' {
+					value = doubleQuoteUnescaper.Replace(value)
+				}
+				args = append(args, rawArg{value, true, i})
+				i = end + 1
+				continue
+			}
+		}
+		end := len(text)
+		if index := strings.IndexFunc(text[i:], unicode.IsSpace); index != -1 {
+			end = i + index
+		}
+		args = append(args, rawArg{text[i:end], false, i})
+		i = end
+	}
+	return args
+}
+
+func spanEnd(spans [][2]int, start int) int {
+	for _, span := range spans {
+		if span[0] == start && span[1] > start {
+			return span[1]
+		}
+	}
+	return -1
+}
+
+// mentionSpans returns byte ranges of text mentions within text,
+// which must be the end of message text or caption (e. g. CommandArgs(message.Text)).
+func mentionSpans(message *tgbotapi.Message, text string) [][2]int {
+	if message == nil {
+		return nil
+	}
+	full, entities := message.Text, message.Entities
+	if full == "" {
+		full, entities = message.Caption, message.CaptionEntities
+	}
+	trimmed := strings.TrimRightFunc(full, unicode.IsSpace)
+	if !strings.HasSuffix(trimmed, text) {
+		return nil
+	}
+	base := len(trimmed) - len(text)
+	spans := [][2]int{}
+	for _, entity := range entities {
+		if entity.Type != "text_mention" {
+			continue
+		}
+		start, end := byteOffset(full, entity.Offset), byteOffset(full, entity.Offset+entity.Length)
+		if start < base || end == -1 || end > len(trimmed) {
+			continue
+		}
+		spans = append(spans, [2]int{start - base, end - base})
+	}
+	return spans
+}
+
+// SplitArgs splits text into arguments: arguments are separated by any whitespace (including newlines)
+// and single or double quotes at the start of an argument group words together.
+// Quotes within words (e. g. apostrophes) and backslashes are kept as is,
+// except for `\"` & `\\` within double quotes.
+//
+// For example, `foo "bar baz" it's 'a b'` is split into []string{"foo", "bar baz", "it's", "a b"}.
+func SplitArgs(text string) []string {
+	result := []string{}
+	for _, arg := range splitArgs(text, nil) {
+		result = append(result, arg.value)
+	}
+	return result
+}
+
+// CommandArgs returns text of command message without the command itself,
+// e. g. "foo bar" for "/cmd@bot_name foo bar".
+func CommandArgs(text string) string {
+	if !strings.HasPrefix(text, "/") {
+		return text
+	}
+	index := strings.IndexFunc(text, unicode.IsSpace)
+	if index == -1 {
+		return ""
+	}
+	return strings.TrimSpace(text[index:])
+}
+
+// Param describes a typed command argument. Params are created with String, Int, Float, Duration, UserRef, Choice & Rest
+// and passed to Args.
+type Param struct {
+	// Name is used to store value in u.Context["params"], to pass value by name (`name=value` or `--name=value`)
+	// and in usage.
+	Name string
+	// Type is a human-readable type of value displayed in usage, e. g. "duration".
+	Type string
+
+	optional     bool
+	defaultValue interface{}
+	rest         bool
+	parse        func(u *Update, value string) (interface{}, error)
+	fallback     func(u *Update) (interface{}, bool)
+}
+
+// Optional marks param as optional. Missing optional params are not stored in u.Context["params"].
+// This function returns the receiver for convenient chaining.
+func (p *Param) Optional() *Param {
+	p.optional = true
+	return p
+}
+
+// Default marks param as optional and sets a value to use when it is missing.
+// This function returns the receiver for convenient chaining.
+func (p *Param) Default(value interface{}) *Param {
+	p.optional = true
+	p.defaultValue = value
+	return p
+}
+
+func (p *Param) usage() string {
+	s := p.Name
+	if p.Type != "" {
+		s += ":" + p.Type
+	}
+	if p.rest {
+		s += "..."
+	}
+	if p.optional {
+		return "[" + s + "]"
+	}
+	return "<" + s + ">"
+}
+
+func newParam(name string, typeName string, parse func(u *Update, value string) (interface{}, error)) *Param {
+	return &Param{Name: name, Type: typeName, parse: parse}
+}
+
+// String creates a string param.
+func String(name string) *Param {
+	return newParam(name, "", func(u *Update, value string) (interface{}, error) {
+		return value, nil
+	})
+}
+
+// Int creates an integer param. Its value is stored as int64.
+func Int(name string) *Param {
+	return newParam(name, "int", func(u *Update, value string) (interface{}, error) {
+		return strconv.ParseInt(value, 10, 64)
+	})
+}
+
+// Float creates a floating point param. Its value is stored as float64.
+func Float(name string) *Param {
+	return newParam(name, "number", func(u *Update, value string) (interface{}, error) {
+		return strconv.ParseFloat(value, 64)
+	})
+}
+
+var durationPrefixRegex = regexp.MustCompile(`^(\d+)([wd])`)
+
+// ParseDuration is like time.ParseDuration but also supports days ("d") & weeks ("w"), e. g. "1w2d12h".
+func ParseDuration(s string) (time.Duration, error) {
+	original := s
+	var result time.Duration
+	for {
+		matches := durationPrefixRegex.FindStringSubmatch(s)
+		if matches == nil {
+			break
+		}
+		n, err := strconv.ParseInt(matches[1], 10, 64)
+		if err != nil {
+			return 0, fmt.Errorf("invalid duration %q", original)
+		}
+		unit := 24 * time.Hour
+		if matches[2] == "w" {
+			unit *= 7
+		}
+		result += time.Duration(n) * unit
+		s = s[len(matches[0]):]
+	}
+	if s == "" {
+		if result == 0 && original == "" {
+			return 0, fmt.Errorf("invalid duration %q", original)
+		}
+		return result, nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid duration %q", original)
+	}
+	return result + d, nil
+}
+
+// Duration creates a duration param, e. g. "30m" or "1d12h" (see ParseDuration). Its value is stored as time.Duration.
+func Duration(name string) *Param {
+	return newParam(name, "duration", func(u *Update, value string) (interface{}, error) {
+		return ParseDuration(value)
+	})
+}
+
+// UserRef creates a param which refers to a user: either numeric ID, "@username" or a text mention.
+// If param is missing (or the next positional argument does not look like a user) and command message is a reply,
+// author of the original message is used, so both "/ban @foo 1h" and "/ban 1h" (sent as a reply) work.
+//
+// Text mention is taken as a single argument, even if display name of the user contains spaces
+// (e. g. "/ban John Smith 1h"), as long as the parsed text is the end of message text (see CommandArgs).
+//
+// Its value is stored as *tgbotapi.User. Keep in mind that only ID or UserName is known unless user was mentioned
+// with a text mention or referred to by reply.
+func UserRef(name string) *Param {
+	p := newParam(name, "user", func(u *Update, value string) (interface{}, error) {
+		if id, err := strconv.ParseInt(value, 10, 64); err == nil {
+			return &tgbotapi.User{ID: id}, nil
+		}
+		for _, entity := range Entities(u.EffectiveMessage()) {
+			if entity.Type == "text_mention" && entity.User != nil && entity.Text == value {
+				return entity.User, nil
+			}
+		}
+		if strings.HasPrefix(value, "@") && len(value) > 1 {
+			return &tgbotapi.User{UserName: value[1:]}, nil
+		}
+		return nil, fmt.Errorf("%q is neither user ID nor @username", value)
+	})
+	p.fallback = func(u *Update) (interface{}, bool) {
+		message := u.EffectiveMessage()
+		if message == nil || message.ReplyToMessage == nil || message.ReplyToMessage.From == nil {
+			return nil, false
+		}
+		return message.ReplyToMessage.From, true
+	}
+	return p
+}
+
+// Choice creates a string param which accepts only specific values.
+func Choice(name string, choices ...string) *Param {
+	return newParam(name, strings.Join(choices, "|"), func(u *Update, value string) (interface{}, error) {
+		for _, choice := range choices {
+			if value == choice {
+				return value, nil
+			}
+		}
+		return nil, fmt.Errorf("expected one of %s", strings.Join(choices, ", "))
+	})
+}
+
+// Rest creates a string param which consumes the rest of the text, starting from the first remaining positional argument.
+// Whitespace & newlines are preserved and arguments within the rest of the text are not parsed as named params.
+// If the rest of the text is a single quoted argument, quotes are removed. It must be the last param.
+func Rest(name string) *Param {
+	p := String(name)
+	p.rest = true
+	return p
+}
+
+// ArgsError describes invalid or missing command argument.
+type ArgsError struct {
+	Param string
+	Err   error
+}
+
+func (e *ArgsError) Error() string {
+	if e.Param == "" {
+		return e.Err.Error()
+	}
+	return fmt.Sprintf("%s: %s", e.Param, e.Err)
+}
+
+func (e *ArgsError) Unwrap() error {
+	return e.Err
+}
+
+// ParseParams parses command arguments (see CommandArgs) according to params.
+//
+// Params can be passed positionally or by name: `name=value`, `--name=value` or `--name` (which means "true").
+// Quoted arguments are always positional.
+func ParseParams(u *Update, text string, params ...*Param) (Map, error) {
+	args := splitArgs(text, mentionSpans(u.EffectiveMessage(), text))
+	values, restStart, lastNamed, err := parseParams(u, text, args, -1, params)
+	if err == nil && (restStart == -1 || lastNamed < restStart) {
+		return values, nil
+	}
+	if len(params) > 0 && params[len(params)-1].rest {
+		// Arguments within the rest of the text must not be parsed as named, so find where the rest starts
+		for i := len(args) - 1; i >= 0; i-- {
+			retried, start, _, retryErr := parseParams(u, text, args, args[i].start, params)
+			if retryErr == nil && start == args[i].start {
+				return retried, nil
+			}
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	return values, nil
+}
+
+// parseParams parses arguments. Arguments which start at restFrom byte offset or later are always positional.
+// Returns offset of text consumed by Rest param (or -1) & offset of the last named argument (or -1).
+func parseParams(u *Update, text string, args []rawArg, restFrom int, params []*Param) (Map, int, int, error) {
+	byName := make(map[string]*Param)
+	for _, param := range params {
+		byName[param.Name] = param
+	}
+	raw := make(map[string]string)
+	positional := []rawArg{}
+	lastNamed := -1
+	for _, arg := range args {
+		value := arg.value
+		if !arg.quoted && (restFrom == -1 || arg.start < restFrom) {
+			name, assigned := "", ""
+			if strings.HasPrefix(value, "--") {
+				name = strings.TrimPrefix(value, "--")
+				assigned = "true"
+				if index := strings.Index(name, "="); index != -1 {
+					name, assigned = name[:index], name[index+1:]
+				}
+				if _, ok := byName[name]; !ok {
+					return nil, -1, -1, &ArgsError{Err: fmt.Errorf("unknown option --%s", name)}
+				}
+			} else if index := strings.Index(value, "="); index > 0 {
+				if _, ok := byName[value[:index]]; ok {
+					name, assigned = value[:index], value[index+1:]
+				}
+			}
+			if name != "" {
+				raw[name] = assigned
+				lastNamed = arg.start
+				continue
+			}
+		}
+		positional = append(positional, arg)
+	}
+	values := make(Map)
+	restStart := -1
+	for _, param := range params {
+		value, ok := raw[param.Name]
+		taken := 0
+		if !ok && len(positional) > 0 {
+			value, taken, ok = positional[0].value, 1, true
+			if param.rest {
+				if len(positional) > 1 || !positional[0].quoted {
+					value = strings.TrimRightFunc(text[positional[0].start:], unicode.IsSpace)
+				}
+				taken = len(positional)
+				restStart = positional[0].start
+			}
+		}
+		var fallback interface{}
+		hasFallback := false
+		if param.fallback != nil {
+			fallback, hasFallback = param.fallback(u)
+		}
+		if !ok {
+			if hasFallback {
+				values[param.Name] = fallback
+				continue
+			}
+			if !param.optional {
+				return nil, -1, -1, &ArgsError{param.Name, fmt.Errorf("missing value")}
+			}
+			if param.defaultValue != nil {
+				values[param.Name] = param.defaultValue
+			}
+			continue
+		}
+		parsed, err := param.parse(u, value)
+		if err != nil {
+			if taken > 0 && hasFallback {
+				// Positional argument belongs to the next param, e. g. "/ban 1h" sent as a reply
+				values[param.Name] = fallback
+				continue
+			}
+			if numErr, isNumErr := err.(*strconv.NumError); isNumErr {
+				err = fmt.Errorf("invalid %s %q", param.Type, numErr.Num)
+			}
+			return nil, -1, -1, &ArgsError{param.Name, err}
+		}
+		positional = positional[taken:]
+		values[param.Name] = parsed
+	}
+	if len(positional) > 0 {
+		return nil, -1, -1, &ArgsError{Err: fmt.Errorf("too many arguments")}
+	}
+	return values, restStart, lastNamed, nil
+}
+
+// Usage returns usage string for a command, e. g. "/ban <target:user> [for:duration]".
+func Usage(command string, params ...*Param) string {
+	parts := []string{"/" + command}
+	for _, param := range params {
+		parts = append(parts, param.usage())
+	}
+	return strings.Join(parts, " ")
+}
+
+// OnArgsError is called by Args filter when command arguments are invalid.
+// By default, it replies with the error & usage.
+var OnArgsError = func(u *Update, err error, usage string) {
+	message := u.EffectiveMessage()
+	if u.Bot == nil || message == nil || message.Chat == nil {
+		return
+	}
+	reply := tgbotapi.NewMessage(message.Chat.ID, fmt.Sprintf("%s\nUsage: %s", err, usage))
+	reply.ReplyToMessageID = message.MessageID
+	u.Bot.Send(reply)
+}
+
+// Args creates a filter which parses command arguments according to params (see ParseParams)
+// and populates u.Context["params"] with a Map of parsed values. It is meant to be used with NewCommandHandler:
+//
+//	tm.NewCommandHandler("ban", tm.Args(tm.UserRef("target"), tm.Duration("for").Optional()), func(u *tm.Update) {
+//	    target := u.Context["params"].(tm.Map)["target"].(*tgbotapi.User)
+//	    // ...
+//	})
+//
+// If arguments are invalid, OnArgsError is called and the update is consumed, so handle functions are not called.
+// The filter still passes in this case, so the update is not processed by other handlers.
+// When NewHelpHandler checks which commands are available, Args always passes.
+func Args(params ...*Param) FilterFunc {
+	return func(u *Update) bool {
+		message := u.EffectiveMessage()
+		if message == nil {
+			return false
+		}
+		if u.probing {
+			return true
+		}
+		values, err := ParseParams(u, CommandArgs(message.Text), params...)
+		if err != nil {
+			command := ""
+			if matches := commandRegex.FindStringSubmatch(message.Text); matches != nil {
+				command = matches[1]
+			}
+			OnArgsError(u, err, Usage(command, params...))
+			u.Consume()
+			return true
+		}
+		u.setContext("params", values)
+		return true
+	}
+}
@@ -0,0 +1,179 @@
+package telemux_test
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	tm "github.com/and3rson/telemux/v2"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+func TestSplitArgs(t *testing.T) {
+	cases := map[string][]string{
+		``:                          {},
+		`foo  bar`:                  {"foo", "bar"},
+		"foo\nbar\t baz":            {"foo", "bar", "baz"},
+		`"foo bar" 'baz \qux'`:      {"foo bar", `baz \qux`},
+		`"a\"b" "c\\d\e" ""`:        {`a"b`, `c\d\e`, ""},
+		`--for=1h key="some value"`: {"--for=1h", `key="some`, `value"`},
+		`Привіт "світ"`:             {"Привіт", "світ"},
+		`I'm here, it's fine`:       {"I'm", "here,", "it's", "fine"},
+		`'tis "unterminated`:        {"'tis", `"unterminated`},
+		`'I'm here' "foo"bar`:       {"I'm here", `"foo"bar`},
+		`\d+ foo\ bar C:\path\`:     {`\d+`, `foo\`, "bar", `C:\path\`},
+		"\"\xff\" \xff'":            {"\xff", "\xff'"},
+	}
+	for text, expected := range cases {
+		args := tm.SplitArgs(text)
+		assert(reflect.DeepEqual(args, expected), t, text, args)
+	}
+
+	assert(tm.CommandArgs("/cmd@bot foo  bar ") == "foo  bar", t)
+	assert(tm.CommandArgs("/cmd\nfoo") == "foo", t)
+	assert(tm.CommandArgs("/cmd") == "", t)
+}
+
+func TestParseDuration(t *testing.T) {
+	cases := map[string]time.Duration{
+		"30m":   30 * time.Minute,
+		"1d12h": 36 * time.Hour,
+		"1w2d":  9 * 24 * time.Hour,
+		"1h30m": 90 * time.Minute,
+		"0d":    0,
+	}
+	for s, expected := range cases {
+		d, err := tm.ParseDuration(s)
+		assert(err == nil && d == expected, t, s, d, err)
+	}
+	for _, s := range []string{"", "1x", "d", "1d1x"} {
+		_, err := tm.ParseDuration(s)
+		assert(err != nil, t, s)
+	}
+}
+
+func TestParseParams(t *testing.T) {
+	u := &tm.Update{}
+	u.Message = &tgbotapi.Message{}
+	params := []*tm.Param{tm.UserRef("target"), tm.Duration("for").Optional(), tm.Rest("reason").Default("spam")}
+
+	values, err := tm.ParseParams(u, `@foo 1d being "rude"`, params...)
+	assert(err == nil, t, err)
+	assert(values["target"].(*tgbotapi.User).UserName == "foo", t, values)
+	assert(values["for"] == 24*time.Hour, t, values)
+	assert(values["reason"] == `being "rude"`, t, values)
+
+	// Rest keeps the text as is
+	values, err = tm.ParseParams(u, "42 1h I'm  back,\nfor=good --for \\o/ ", params...)
+	assert(err == nil, t, err)
+	assert(values["reason"] == "I'm  back,\nfor=good --for \\o/", t, values)
+	assert(values["for"] == time.Hour, t, values)
+	values, err = tm.ParseParams(u, `42 --for=1h "just because"`, params...)
+	assert(err == nil && values["reason"] == "just because" && values["for"] == time.Hour, t, values, err)
+
+	values, err = tm.ParseParams(u, `for=2h 42`, params...)
+	assert(err == nil, t, err)
+	assert(values["target"].(*tgbotapi.User).ID == 42, t, values)
+	assert(values["for"] == 2*time.Hour, t, values)
+	assert(values["reason"] == "spam", t, values)
+
+	values, err = tm.ParseParams(u, `--for=1m 42 "for=reason"`, params...)
+	assert(err == nil && values["reason"] == "for=reason", t, values, err)
+
+	_, err = tm.ParseParams(u, ``, params...)
+	var argsErr *tm.ArgsError
+	assert(errors.As(err, &argsErr) && argsErr.Param == "target", t, err)
+	assert(err.Error() == "target: missing value", t, err)
+
+	_, err = tm.ParseParams(u, `foo`, params...)
+	assert(err != nil && err.Error() == `target: "foo" is neither user ID nor @username`, t, err)
+
+	_, err = tm.ParseParams(u, `42 soon`, params...)
+	assert(err != nil && err.Error() == `for: invalid duration "soon"`, t, err)
+
+	_, err = tm.ParseParams(u, `42 --unknown`, params...)
+	assert(err != nil && err.Error() == `unknown option --unknown`, t, err)
+
+	_, err = tm.ParseParams(u, `1 2`, tm.Int("n"))
+	assert(err != nil && err.Error() == `too many arguments`, t, err)
+
+	_, err = tm.ParseParams(u, `x`, tm.Int("n"))
+	assert(err != nil && err.Error() == `n: invalid int "x"`, t, err)
+
+	values, err = tm.ParseParams(u, `b 1.5`, tm.Choice("mode", "a", "b"), tm.Float("ratio"))
+	assert(err == nil && values["mode"] == "b" && values["ratio"] == 1.5, t, values, err)
+	_, err = tm.ParseParams(u, `c 1`, tm.Choice("mode", "a", "b"), tm.Float("ratio"))
+	assert(err != nil && err.Error() == `mode: expected one of a, b`, t, err)
+
+	// Reply & text mention
+	author := &tgbotapi.User{ID: 13}
+	u.Message.ReplyToMessage = &tgbotapi.Message{From: author}
+	values, err = tm.ParseParams(u, `1h`, params...)
+	assert(err == nil && values["target"] == author && values["for"] == time.Hour, t, values, err)
+	mentioned := &tgbotapi.User{ID: 7}
+	u.Message.Text = "/ban John Doe"
+	u.Message.Entities = []tgbotapi.MessageEntity{{Type: "text_mention", Offset: 5, Length: 8, User: mentioned}}
+	values, err = tm.ParseParams(u, `"John Doe"`, params...)
+	assert(err == nil && values["target"] == mentioned, t, values, err)
+
+	// Text mentions with spaces are single arguments
+	u.Message.ReplyToMessage = nil
+	u.Message.Text = "/ban John Smith 1h  "
+	u.Message.Entities = []tgbotapi.MessageEntity{{Type: "text_mention", Offset: 5, Length: 10, User: mentioned}}
+	values, err = tm.ParseParams(u, tm.CommandArgs(u.Message.Text), params...)
+	assert(err == nil && values["target"] == mentioned && values["for"] == time.Hour, t, values, err)
+	u.Message.Text = "/ban 😀 Іван Петренко 1d being rude"
+	u.Message.Entities = []tgbotapi.MessageEntity{{Type: "text_mention", Offset: 5, Length: 16, User: mentioned}}
+	values, err = tm.ParseParams(u, tm.CommandArgs(u.Message.Text), params...)
+	assert(err == nil && values["target"] == mentioned && values["reason"] == "being rude", t, values, err)
+}
+
+func TestArgsFilter(t *testing.T) {
+	NewTGUpdate := func(text string) tgbotapi.Update {
+		u := tgbotapi.Update{}
+		u.Message = &tgbotapi.Message{Text: text, Chat: &tgbotapi.Chat{}}
+		return u
+	}
+
+	var usage string
+	defer func(original func(u *tm.Update, err error, usage string)) { tm.OnArgsError = original }(tm.OnArgsError)
+	tm.OnArgsError = func(u *tm.Update, err error, u2 string) { usage = u2 }
+
+	var params tm.Map
+	calls := 0
+	mux := tm.NewMux().
+		AddHandler(tm.NewCommandHandler("ban", tm.Args(tm.UserRef("target"), tm.Duration("for").Optional()), func(u *tm.Update) {
+			params = u.Context["params"].(tm.Map)
+			calls++
+		})).
+		AddHandler(tm.NewHandler(tm.Any(), func(u *tm.Update) { t.Error("should not be called") }))
+
+	assert(mux.Dispatch(nil, NewTGUpdate("/ban 42 for=1d")), t)
+	assert(calls == 1 && params["target"].(*tgbotapi.User).ID == 42 && params["for"] == 24*time.Hour, t, params)
+
+	assert(mux.Dispatch(nil, NewTGUpdate("/ban")), t)
+	assert(calls == 1, t)
+	assert(usage == "/ban <target:user> [for:duration]", t, usage)
+}
+
+func TestCommandHandlerArgs(t *testing.T) {
+	var args []string
+	mux := tm.NewMux().AddHandler(tm.NewCommandHandler("echo note grep", nil, func(u *tm.Update) {
+		args = u.Context["args"].([]string)
+	}))
+	u := tgbotapi.Update{Message: &tgbotapi.Message{Text: "/echo  foo \"bar baz\"\nqux", Chat: &tgbotapi.Chat{}}}
+	assert(mux.Dispatch(nil, u), t)
+	assert(reflect.DeepEqual(args, []string{"foo", "bar baz", "qux"}), t, args)
+	u.Message.Text = `/echo "foo bar`
+	assert(mux.Dispatch(nil, u), t)
+	assert(reflect.DeepEqual(args, []string{`"foo`, "bar"}), t, args)
+
+	// Apostrophes & backslashes in ordinary text are kept
+	u.Message.Text = `/note I'm here, it's fine`
+	assert(mux.Dispatch(nil, u), t)
+	assert(reflect.DeepEqual(args, []string{"I'm", "here,", "it's", "fine"}), t, args)
+	u.Message.Text = `/grep \d+ foo`
+	assert(mux.Dispatch(nil, u), t)
+	assert(reflect.DeepEqual(args, []string{`\d+`, "foo"}), t, args)
+}
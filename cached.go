@@ -0,0 +1,262 @@
+package telemux
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+// CacheOptions configures CachedPersistence.
+type CacheOptions struct {
+	// Size is a maximum number of conversations kept in cache. Defaults to 1000.
+	Size int
+	// WriteBehind tells persistence to delay writes to inner persistence until the next flush.
+	// Otherwise every write is passed to inner persistence immediately (write-through).
+	WriteBehind bool
+	// FlushInterval defines how often changes are flushed to inner persistence in write-behind mode. Defaults to 1 second.
+	FlushInterval time.Duration
+}
+
+// CacheStats contains cache statistics of CachedPersistence.
+type CacheStats struct {
+	Hits      uint64
+	Misses    uint64
+	Evictions uint64
+	Flushes   uint64
+}
+
+type cacheEntry struct {
+	pk         PersistenceKey
+	state      string
+	data       Data
+	hasState   bool
+	hasData    bool
+	dirtyState bool
+	dirtyData  bool
+}
+
+// CachedPersistence is a decorator which keeps recently used conversations in a LRU cache
+// to reduce the number of reads from another (usually slow) persistence, e. g. GORMPersistence.
+//
+// In write-behind mode, changes are flushed periodically, when a changed conversation is evicted from cache,
+// and when Flush or Close is called. Make sure to call Close before the application exits,
+// otherwise recent changes may be lost.
+//
+// Data is copied when it is passed to or returned from cache, so modifying the returned Data does not affect
+// the cache until SetData is called (same as with FilePersistence).
+//
+// Cache assumes it is the only writer to inner persistence.
+type CachedPersistence struct {
+	Inner   ConversationPersistence
+	Options CacheOptions
+
+	mutex   sync.Mutex
+	entries map[PersistenceKey]*list.Element
+	lru     *list.List
+	stats   CacheStats
+	done    chan struct{}
+	stopped chan struct{}
+}
+
+// NewCachedPersistence creates new instance of CachedPersistence.
+// In write-behind mode it also starts a goroutine which flushes changes every FlushInterval until Close is called.
+func NewCachedPersistence(inner ConversationPersistence, opts CacheOptions) *CachedPersistence {
+	if opts.Size <= 0 {
+		opts.Size = 1000
+	}
+	if opts.FlushInterval <= 0 {
+		opts.FlushInterval = time.Second
+	}
+	p := &CachedPersistence{
+		Inner:   inner,
+		Options: opts,
+		entries: make(map[PersistenceKey]*list.Element),
+		lru:     list.New(),
+	}
+	if opts.WriteBehind {
+		p.done = make(chan struct{})
+		p.stopped = make(chan struct{})
+		go p.flushPeriodically()
+	}
+	return p
+}
+
+func (p *CachedPersistence) flushPeriodically() {
+	defer close(p.stopped)
+	ticker := time.NewTicker(p.Options.FlushInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			p.Flush()
+		case <-p.done:
+			return
+		}
+	}
+}
+
+// entry returns cache entry for key, creating it if necessary. Must be called with mutex held.
+func (p *CachedPersistence) entry(pk PersistenceKey) *cacheEntry {
+	if element, ok := p.entries[pk]; ok {
+		p.lru.MoveToFront(element)
+		return element.Value.(*cacheEntry)
+	}
+	entry := &cacheEntry{pk: pk}
+	p.entries[pk] = p.lru.PushFront(entry)
+	for p.lru.Len() > p.Options.Size {
+		oldest := p.lru.Back()
+		p.flushEntry(oldest.Value.(*cacheEntry))
+		p.lru.Remove(oldest)
+		delete(p.entries, oldest.Value.(*cacheEntry).pk)
+		p.stats.Evictions++
+	}
+	return entry
+}
+
+// flushEntry writes changed values of entry to inner persistence. Must be called with mutex held.
+func (p *CachedPersistence) flushEntry(entry *cacheEntry) {
+	if entry.dirtyState {
+		p.Inner.SetState(entry.pk, entry.state)
+		entry.dirtyState = false
+	}
+	if entry.dirtyData {
+		p.Inner.SetData(entry.pk, copyData(entry.data))
+		entry.dirtyData = false
+	}
+}
+
+// copyData makes a shallow copy of data, so callers cannot modify cached data without the mutex.
+func copyData(data Data) Data {
+	if data == nil {
+		return nil
+	}
+	copied := make(Data, len(data))
+	for key, value := range data {
+		copied[key] = value
+	}
+	return copied
+}
+
+// GetState returns conversation state from cache or inner persistence
+func (p *CachedPersistence) GetState(pk PersistenceKey) string {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	entry := p.entry(pk)
+	if entry.hasState {
+		p.stats.Hits++
+	} else {
+		p.stats.Misses++
+		entry.state = p.Inner.GetState(pk)
+		entry.hasState = true
+	}
+	return entry.state
+}
+
+// SetState stores conversation state in cache & inner persistence
+func (p *CachedPersistence) SetState(pk PersistenceKey, state string) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	entry := p.entry(pk)
+	entry.state = state
+	entry.hasState = true
+	if p.Options.WriteBehind {
+		entry.dirtyState = true
+	} else {
+		p.Inner.SetState(pk, state)
+	}
+}
+
+// GetData returns conversation data from cache or inner persistence
+func (p *CachedPersistence) GetData(pk PersistenceKey) Data {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	entry := p.entry(pk)
+	if entry.hasData {
+		p.stats.Hits++
+	} else {
+		p.stats.Misses++
+		entry.data = p.Inner.GetData(pk)
+		entry.hasData = true
+	}
+	return copyData(entry.data)
+}
+
+// SetData stores conversation data in cache & inner persistence
+func (p *CachedPersistence) SetData(pk PersistenceKey, data Data) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	entry := p.entry(pk)
+	entry.data = copyData(data)
+	entry.hasData = true
+	if p.Options.WriteBehind {
+		entry.dirtyData = true
+	} else {
+		p.Inner.SetData(pk, data)
+	}
+}
+
+// Flush writes all pending changes to inner persistence.
+func (p *CachedPersistence) Flush() {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	for element := p.lru.Front(); element != nil; element = element.Next() {
+		p.flushEntry(element.Value.(*cacheEntry))
+	}
+	p.stats.Flushes++
+}
+
+// Close stops periodic flushing and writes all pending changes to inner persistence.
+func (p *CachedPersistence) Close() {
+	if p.done != nil {
+		close(p.done)
+		<-p.stopped
+		p.done = nil
+	}
+	p.Flush()
+}
+
+// Invalidate removes all conversations from cache, flushing pending changes first.
+// Use it if inner persistence was modified by someone else.
+func (p *CachedPersistence) Invalidate() {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	for element := p.lru.Front(); element != nil; element = element.Next() {
+		p.flushEntry(element.Value.(*cacheEntry))
+	}
+	p.entries = make(map[PersistenceKey]*list.Element)
+	p.lru.Init()
+}
+
+func (p *CachedPersistence) inner() ConversationPersistence {
+	return p.Inner
+}
+
+// Keys returns keys of inner persistence together with keys of conversations which were not flushed yet.
+// Inner persistence must implement Iterable interface.
+func (p *CachedPersistence) Keys() ([]PersistenceKey, error) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	keys, err := innerKeys(p.Inner)
+	if err != nil {
+		return nil, err
+	}
+	known := make(map[PersistenceKey]bool)
+	for _, pk := range keys {
+		known[pk] = true
+	}
+	for pk, element := range p.entries {
+		entry := element.Value.(*cacheEntry)
+		if (entry.dirtyState || entry.dirtyData) && !known[pk] {
+			keys = append(keys, pk)
+		}
+	}
+	SortKeys(keys)
+	return keys, nil
+}
+
+// Stats returns cache statistics.
+func (p *CachedPersistence) Stats() CacheStats {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	return p.stats
+}
@@ -0,0 +1,127 @@
+package telemux_test
+
+import (
+	"io/ioutil"
+	"os"
+	"reflect"
+	"testing"
+	"time"
+
+	tm "github.com/and3rson/telemux/v2"
+)
+
+type countingPersistence struct {
+	tm.ConversationPersistence
+	reads  int
+	writes int
+}
+
+func (p *countingPersistence) GetState(pk tm.PersistenceKey) string {
+	p.reads++
+	return p.ConversationPersistence.GetState(pk)
+}
+
+func (p *countingPersistence) SetState(pk tm.PersistenceKey, state string) {
+	p.writes++
+	p.ConversationPersistence.SetState(pk, state)
+}
+
+func (p *countingPersistence) GetData(pk tm.PersistenceKey) tm.Data {
+	p.reads++
+	return p.ConversationPersistence.GetData(pk)
+}
+
+func (p *countingPersistence) SetData(pk tm.PersistenceKey, data tm.Data) {
+	p.writes++
+	p.ConversationPersistence.SetData(pk, data)
+}
+
+func TestCachedPersistenceWriteThrough(t *testing.T) {
+	inner := &countingPersistence{ConversationPersistence: tm.NewLocalPersistence()}
+	p := tm.NewCachedPersistence(inner, tm.CacheOptions{Size: 2})
+	pk1 := tm.PersistenceKey{"foo", 1, 1}
+	pk2 := tm.PersistenceKey{"foo", 2, 2}
+	pk3 := tm.PersistenceKey{"foo", 3, 3}
+
+	assert(p.GetState(pk1) == "", t)
+	assert(p.GetState(pk1) == "", t)
+	assert(inner.reads == 1, t, inner.reads)
+	p.SetState(pk1, "state1")
+	assert(inner.writes == 1, t)
+	assert(p.GetState(pk1) == "state1", t)
+	assert(inner.reads == 1, t)
+
+	p.SetData(pk2, tm.Data{"foo": "bar"})
+	assert(reflect.DeepEqual(p.GetData(pk2), tm.Data{"foo": "bar"}), t)
+	assert(inner.reads == 1, t)
+
+	// pk1 is the least recently used & gets evicted
+	p.GetState(pk3)
+	p.GetState(pk1)
+	assert(inner.reads == 3, t, inner.reads)
+	assert(p.Stats() == tm.CacheStats{Hits: 3, Misses: 3, Evictions: 2}, t, p.Stats())
+}
+
+func TestCachedPersistenceWriteBehind(t *testing.T) {
+	inner := &countingPersistence{ConversationPersistence: tm.NewLocalPersistence()}
+	p := tm.NewCachedPersistence(inner, tm.CacheOptions{Size: 1, WriteBehind: true, FlushInterval: time.Hour})
+	pk1 := tm.PersistenceKey{"foo", 1, 1}
+	pk2 := tm.PersistenceKey{"foo", 2, 2}
+
+	p.SetState(pk1, "state1")
+	p.SetState(pk1, "state2")
+	p.SetData(pk1, tm.Data{"foo": "bar"})
+	assert(inner.writes == 0, t)
+	assert(p.GetState(pk1) == "state2", t)
+
+	// Evicting changed conversation writes it to inner persistence
+	p.SetState(pk2, "state3")
+	assert(inner.writes == 2, t, inner.writes)
+	assert(inner.ConversationPersistence.GetState(pk1) == "state2", t)
+
+	p.Close()
+	assert(inner.writes == 3, t, inner.writes)
+	assert(inner.ConversationPersistence.GetState(pk2) == "state3", t)
+	p.Flush()
+	assert(inner.writes == 3, t, inner.writes)
+	assert(p.Stats().Flushes == 2, t)
+}
+
+func TestCachedPersistencePeriodicFlush(t *testing.T) {
+	inner := tm.NewLocalPersistence()
+	p := tm.NewCachedPersistence(inner, tm.CacheOptions{WriteBehind: true, FlushInterval: time.Millisecond})
+	defer p.Close()
+	pk := tm.PersistenceKey{"foo", 1, 1}
+	p.SetState(pk, "state1")
+	deadline := time.Now().Add(time.Second)
+	for p.Stats().Flushes == 0 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	p.Invalidate()
+	assert(inner.GetState(pk) == "state1", t)
+}
+
+func TestCachedPersistenceConcurrentFlush(t *testing.T) {
+	f, err := ioutil.TempFile("", "telemux_cached")
+	if err != nil {
+		t.Error("Failed to create temporary file")
+	}
+	f.Close()
+	os.Remove(f.Name())
+	defer os.Remove(f.Name())
+
+	inner := tm.NewFilePersistence(f.Name())
+	p := tm.NewCachedPersistence(inner, tm.CacheOptions{WriteBehind: true, FlushInterval: time.Millisecond})
+	pk := tm.PersistenceKey{"foo", 1, 1}
+	context := &tm.PersistenceContext{Persistence: p, PK: pk}
+	// Run with -race: data must not be modified while it is being flushed
+	i := 0
+	for ; p.Stats().Flushes < 10; i++ {
+		context.PutDataValue("counter", i)
+		context.GetData()["scratch"] = i
+	}
+	p.Close()
+	data := inner.GetData(pk)
+	assert(data["counter"] == float64(i-1), t, data)
+	assert(data["scratch"] == nil, t, data)
+}
@@ -0,0 +1,258 @@
+package telemux
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// MaxCallbackData is the maximum length of callback data allowed by Telegram.
+const MaxCallbackData = 64
+
+// ErrInvalidCallback is returned when callback data cannot be decoded, e. g. if it was forged or expired.
+var ErrInvalidCallback = errors.New("invalid callback data")
+
+// CallbackStore keeps callback payloads which do not fit into MaxCallbackData.
+type CallbackStore interface {
+	PutCallback(id string, body string)
+	GetCallback(id string) (string, bool)
+}
+
+// LocalCallbackStore keeps callback payloads in memory. Payloads are lost on restart and are never removed.
+type LocalCallbackStore struct {
+	mutex    sync.RWMutex
+	payloads map[string]string
+}
+
+// NewLocalCallbackStore creates new instance of LocalCallbackStore.
+func NewLocalCallbackStore() *LocalCallbackStore {
+	return &LocalCallbackStore{payloads: make(map[string]string)}
+}
+
+// PutCallback stores payload.
+func (s *LocalCallbackStore) PutCallback(id string, body string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.payloads[id] = body
+}
+
+// GetCallback retrieves payload.
+func (s *LocalCallbackStore) GetCallback(id string) (string, bool) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	body, ok := s.payloads[id]
+	return body, ok
+}
+
+// CallbackRoute encodes typed payloads into callback data of inline keyboard buttons and decodes them back.
+//
+// Payload is either a struct (encoded compactly as JSON array of its exported field values)
+// or any other JSON-serializable value. Callback data looks like "NAME:BODY" or "NAME:SIGNATURE:BODY" if signing is enabled.
+// If data exceeds MaxCallbackData, body is kept in CallbackStore and data refers to it ("NAME:@ID").
+type CallbackRoute struct {
+	Name  string
+	Type  reflect.Type
+	Store CallbackStore
+	Key   []byte
+}
+
+// NewCallbackRoute creates a route for payloads of the same type as prototype, e. g.
+//
+//	type Vote struct { PollID int64; Option int }
+//	route := tm.NewCallbackRoute("vote", Vote{})
+//
+// name must be unique among routes and must not contain ":".
+func NewCallbackRoute(name string, prototype interface{}) *CallbackRoute {
+	if name == "" || strings.Contains(name, ":") {
+		panic(fmt.Sprintf("invalid callback route name %q", name))
+	}
+	return &CallbackRoute{Name: name, Type: reflect.TypeOf(prototype)}
+}
+
+// SetStore sets store for payloads which do not fit into MaxCallbackData. Without store, Encode fails for such payloads.
+// This function returns the receiver for convenient chaining.
+func (r *CallbackRoute) SetStore(store CallbackStore) *CallbackRoute {
+	r.Store = store
+	return r
+}
+
+// SetSigningKey enables HMAC signing of callback data, so users cannot forge button presses.
+// Data with invalid signature is rejected by Decode & Handler.
+// This function returns the receiver for convenient chaining.
+func (r *CallbackRoute) SetSigningKey(key []byte) *CallbackRoute {
+	r.Key = key
+	return r
+}
+
+func (r *CallbackRoute) structType() (reflect.Type, bool) {
+	t := r.Type
+	if t != nil && t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return t, t != nil && t.Kind() == reflect.Struct
+}
+
+func (r *CallbackRoute) marshal(payload interface{}) (string, error) {
+	if reflect.TypeOf(payload) != r.Type {
+		return "", fmt.Errorf("callback route %q expects %v, got %T", r.Name, r.Type, payload)
+	}
+	t, isStruct := r.structType()
+	if !isStruct {
+		data, err := json.Marshal(payload)
+		return string(data), err
+	}
+	v := reflect.Indirect(reflect.ValueOf(payload))
+	values := []interface{}{}
+	for i := 0; i < t.NumField(); i++ {
+		if t.Field(i).PkgPath == "" {
+			values = append(values, v.Field(i).Interface())
+		}
+	}
+	data, err := json.Marshal(values)
+	return string(data), err
+}
+
+func (r *CallbackRoute) unmarshal(body string) (interface{}, error) {
+	t, isStruct := r.structType()
+	if r.Type == nil {
+		return nil, fmt.Errorf("callback route %q has no payload type", r.Name)
+	}
+	if !isStruct {
+		target := reflect.New(r.Type)
+		if err := json.Unmarshal([]byte(body), target.Interface()); err != nil {
+			return nil, err
+		}
+		return target.Elem().Interface(), nil
+	}
+	var values []json.RawMessage
+	if err := json.Unmarshal([]byte(body), &values); err != nil {
+		return nil, err
+	}
+	target := reflect.New(t).Elem()
+	n := 0
+	for i := 0; i < t.NumField(); i++ {
+		if t.Field(i).PkgPath != "" {
+			continue
+		}
+		if n >= len(values) {
+			return nil, fmt.Errorf("too few values")
+		}
+		if err := json.Unmarshal(values[n], target.Field(i).Addr().Interface()); err != nil {
+			return nil, err
+		}
+		n++
+	}
+	if n != len(values) {
+		return nil, fmt.Errorf("too many values")
+	}
+	if r.Type.Kind() == reflect.Ptr {
+		return target.Addr().Interface(), nil
+	}
+	return target.Interface(), nil
+}
+
+func (r *CallbackRoute) sign(body string) string {
+	mac := hmac.New(sha256.New, r.Key)
+	mac.Write([]byte(r.Name + ":" + body))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil)[:8])
+}
+
+func (r *CallbackRoute) wrap(body string) string {
+	if r.Key != nil {
+		return r.Name + ":" + r.sign(body) + ":" + body
+	}
+	return r.Name + ":" + body
+}
+
+// Encode converts payload into callback data.
+func (r *CallbackRoute) Encode(payload interface{}) (string, error) {
+	body, err := r.marshal(payload)
+	if err != nil {
+		return "", err
+	}
+	data := r.wrap(body)
+	if len(data) <= MaxCallbackData {
+		return data, nil
+	}
+	if r.Store == nil {
+		return "", fmt.Errorf("callback data %q exceeds %d bytes and no store is set", data, MaxCallbackData)
+	}
+	hash := sha256.Sum256([]byte(r.Name + ":" + body))
+	id := base64.RawURLEncoding.EncodeToString(hash[:12])
+	data = r.wrap("@" + id)
+	if len(data) > MaxCallbackData {
+		return "", fmt.Errorf("callback data %q exceeds %d bytes even with payload kept in store, use shorter route name", data, MaxCallbackData)
+	}
+	r.Store.PutCallback(id, body)
+	return data, nil
+}
+
+// Matches checks if callback data belongs to this route.
+func (r *CallbackRoute) Matches(data string) bool {
+	return strings.HasPrefix(data, r.Name+":")
+}
+
+// Decode converts callback data back into payload of route type. Returns ErrInvalidCallback (possibly wrapped)
+// if data does not belong to this route, has invalid signature or refers to unknown stored payload.
+func (r *CallbackRoute) Decode(data string) (interface{}, error) {
+	if !r.Matches(data) {
+		return nil, ErrInvalidCallback
+	}
+	body := strings.TrimPrefix(data, r.Name+":")
+	if r.Key != nil {
+		parts := strings.SplitN(body, ":", 2)
+		if len(parts) != 2 || !hmac.Equal([]byte(parts[0]), []byte(r.sign(parts[1]))) {
+			return nil, fmt.Errorf("%w: bad signature", ErrInvalidCallback)
+		}
+		body = parts[1]
+	}
+	if strings.HasPrefix(body, "@") {
+		if r.Store == nil {
+			return nil, fmt.Errorf("%w: no store is set", ErrInvalidCallback)
+		}
+		stored, ok := r.Store.GetCallback(body[1:])
+		if !ok {
+			return nil, fmt.Errorf("%w: unknown payload %s", ErrInvalidCallback, body)
+		}
+		body = stored
+	}
+	payload, err := r.unmarshal(body)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidCallback, err)
+	}
+	return payload, nil
+}
+
+// Button creates inline keyboard button with encoded payload. Panics if payload cannot be encoded.
+func (r *CallbackRoute) Button(text string, payload interface{}) tgbotapi.InlineKeyboardButton {
+	data, err := r.Encode(payload)
+	if err != nil {
+		panic(err)
+	}
+	return tgbotapi.NewInlineKeyboardButtonData(text, data)
+}
+
+// Handler creates a handler for callback queries of this route.
+// It populates u.Context["payload"] with decoded payload. Callback queries with invalid data are not handled.
+func (r *CallbackRoute) Handler(filter FilterFunc, handles ...HandleFunc) *Handler {
+	newFilter := And(IsCallbackQuery(), func(u *Update) bool {
+		payload, err := r.Decode(u.CallbackQuery.Data)
+		if err != nil {
+			return false
+		}
+		u.setContext("payload", payload)
+		return true
+	})
+	if filter != nil {
+		newFilter = And(newFilter, filter)
+	}
+	return NewHandler(newFilter, handles...)
+}
@@ -0,0 +1,99 @@
+package telemux_test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	tm "github.com/and3rson/telemux/v2"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+type vote struct {
+	PollID int64
+	Option string
+	hidden int
+}
+
+func TestCallbackRoute(t *testing.T) {
+	route := tm.NewCallbackRoute("vote", vote{})
+	data, err := route.Encode(vote{42, "yes", 1})
+	assert(err == nil && data == `vote:[42,"yes"]`, t, data, err)
+	payload, err := route.Decode(data)
+	assert(err == nil && payload == vote{42, "yes", 0}, t, payload, err)
+
+	_, err = route.Encode("foo")
+	assert(err != nil, t)
+	for _, invalid := range []string{`other:[42,"yes"]`, `vote:[42]`, `vote:[42,"yes",1]`, `vote:{`} {
+		_, err = route.Decode(invalid)
+		assert(errors.Is(err, tm.ErrInvalidCallback), t, invalid, err)
+	}
+
+	// Non-struct & pointer payloads
+	pages := tm.NewCallbackRoute("page", 0)
+	data, _ = pages.Encode(3)
+	payload, err = pages.Decode(data)
+	assert(data == "page:3" && payload == 3, t, data, payload, err)
+	pointers := tm.NewCallbackRoute("ptr", &vote{})
+	data, _ = pointers.Encode(&vote{1, "a", 0})
+	payload, err = pointers.Decode(data)
+	assert(err == nil && *payload.(*vote) == vote{1, "a", 0}, t, payload, err)
+}
+
+func TestCallbackRouteOverflow(t *testing.T) {
+	long := vote{1, strings.Repeat("x", 100), 0}
+	route := tm.NewCallbackRoute("vote", vote{})
+	_, err := route.Encode(long)
+	assert(err != nil, t)
+
+	route.SetStore(tm.NewLocalCallbackStore())
+	data, err := route.Encode(long)
+	assert(err == nil && len(data) <= tm.MaxCallbackData && strings.HasPrefix(data, "vote:@"), t, data, err)
+	payload, err := route.Decode(data)
+	assert(err == nil && payload == long, t, payload, err)
+	again, _ := route.Encode(long)
+	assert(again == data, t)
+
+	_, err = route.Decode("vote:@unknown")
+	assert(errors.Is(err, tm.ErrInvalidCallback), t, err)
+	_, err = tm.NewCallbackRoute("vote", vote{}).Decode(data)
+	assert(errors.Is(err, tm.ErrInvalidCallback), t, err)
+
+	// Signed route with a long name does not fit even with payload kept in store
+	longName := tm.NewCallbackRoute(strings.Repeat("n", 40), vote{}).
+		SetStore(tm.NewLocalCallbackStore()).
+		SetSigningKey([]byte("secret"))
+	data, err = longName.Encode(long)
+	assert(err != nil && data == "", t, data, err)
+}
+
+func TestCallbackRouteSigning(t *testing.T) {
+	route := tm.NewCallbackRoute("vote", vote{}).SetSigningKey([]byte("secret"))
+	data, err := route.Encode(vote{42, "yes", 0})
+	assert(err == nil && len(data) <= tm.MaxCallbackData, t, data, err)
+	payload, err := route.Decode(data)
+	assert(err == nil && payload == vote{42, "yes", 0}, t, payload, err)
+
+	forged := strings.Replace(data, "42", "43", 1)
+	_, err = route.Decode(forged)
+	assert(errors.Is(err, tm.ErrInvalidCallback), t, err)
+	_, err = route.Decode(`vote:[42,"yes"]`)
+	assert(errors.Is(err, tm.ErrInvalidCallback), t, err)
+	_, err = tm.NewCallbackRoute("vote", vote{}).SetSigningKey([]byte("other")).Decode(data)
+	assert(errors.Is(err, tm.ErrInvalidCallback), t, err)
+}
+
+func TestCallbackRouteHandler(t *testing.T) {
+	route := tm.NewCallbackRoute("vote", vote{}).SetSigningKey([]byte("secret"))
+	var received interface{}
+	mux := tm.NewMux().AddHandler(route.Handler(nil, func(u *tm.Update) {
+		received = u.Context["payload"]
+	}))
+	button := route.Button("Yes", vote{42, "yes", 0})
+	assert(button.Text == "Yes" && button.CallbackData != nil, t, button)
+	u := tgbotapi.Update{CallbackQuery: &tgbotapi.CallbackQuery{Data: *button.CallbackData}}
+	assert(mux.Dispatch(nil, u), t)
+	assert(received == vote{42, "yes", 0}, t, received)
+	u.CallbackQuery.Data = `vote:AAAAAAAAAAA:[1,"no"]`
+	assert(!mux.Dispatch(nil, u), t)
+}
@@ -0,0 +1,75 @@
+// telemux-persistence exports & imports conversations stored by FilePersistence as JSON Lines.
+//
+// Usage:
+//
+//	telemux-persistence export -file db.json > conversations.jsonl
+//	telemux-persistence import -file db.json < conversations.jsonl
+//
+// The resulting JSON Lines can be imported into any other persistence with telemux.Import,
+// e. g. into GORMPersistence from gormpersistence module.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+
+	tm "github.com/and3rson/telemux/v2"
+)
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "Usage: %s export|import -file FILENAME [-dump DUMP.jsonl]\n", os.Args[0])
+	os.Exit(2)
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+	}
+	command := os.Args[1]
+
+	flags := flag.NewFlagSet(command, flag.ExitOnError)
+	filename := flags.String("file", "", "FilePersistence filename")
+	dump := flags.String("dump", "", "JSON Lines file to export to or import from (default: stdout/stdin)")
+	flags.Parse(os.Args[2:])
+	if *filename == "" {
+		usage()
+	}
+
+	p := tm.NewFilePersistence(*filename)
+
+	switch command {
+	case "export":
+		var w io.Writer = os.Stdout
+		if *dump != "" {
+			f, err := os.Create(*dump)
+			if err != nil {
+				log.Fatal(err)
+			}
+			defer f.Close()
+			w = f
+		}
+		if err := tm.Export(p, w); err != nil {
+			log.Fatal(err)
+		}
+	case "import":
+		var r io.Reader = os.Stdin
+		if *dump != "" {
+			f, err := os.Open(*dump)
+			if err != nil {
+				log.Fatal(err)
+			}
+			defer f.Close()
+			r = f
+		}
+		count, err := tm.Import(p, r)
+		if err != nil {
+			log.Fatal(err)
+		}
+		log.Printf("Imported %d conversations", count)
+	default:
+		usage()
+	}
+}
@@ -0,0 +1,278 @@
+package telemux
+
+import (
+	"sort"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// CommandScope is a type of Telegram bot command scope, e. g. "all_private_chats".
+type CommandScope string
+
+// Command scopes which can be assigned to command handlers with InScope.
+const (
+	ScopeDefault CommandScope = "default"
+	ScopePrivate CommandScope = "all_private_chats"
+	ScopeGroups  CommandScope = "all_group_chats"
+	ScopeAdmins  CommandScope = "all_chat_administrators"
+)
+
+// commandScopes lists all scopes which can be assigned to command handlers.
+var commandScopes = []CommandScope{ScopeDefault, ScopePrivate, ScopeGroups, ScopeAdmins}
+
+// parentScopes lists scopes whose commands are also displayed in a scope.
+// Telegram shows commands of the narrowest scope only, so broader commands must be repeated there.
+var parentScopes = map[CommandScope][]CommandScope{
+	ScopeDefault: {},
+	ScopePrivate: {ScopeDefault},
+	ScopeGroups:  {ScopeDefault},
+	ScopeAdmins:  {ScopeGroups, ScopeDefault},
+}
+
+// CommandInfo describes command(s) handled by a handler created with NewCommandHandler.
+type CommandInfo struct {
+	Names       []string
+	Description string
+	// Descriptions contains localized descriptions by two-letter language code.
+	Descriptions map[string]string
+	// Scopes define where command is displayed. Empty list means ScopeDefault.
+	Scopes []CommandScope
+}
+
+// Command describes a single command collected by Mux.Commands.
+type Command struct {
+	Name         string
+	Description  string
+	Descriptions map[string]string
+	Scopes       []CommandScope
+}
+
+// LocalizedDescription returns description of command in specific language,
+// falling back to default description.
+func (c Command) LocalizedDescription(language string) string {
+	if description, ok := c.Descriptions[language]; ok {
+		return description
+	}
+	return c.Description
+}
+
+func (h *Handler) commandInfo() *CommandInfo {
+	if h.Command == nil {
+		h.Command = &CommandInfo{}
+	}
+	return h.Command
+}
+
+// Describe sets description of command displayed in Telegram command menu & help (see Mux.SyncCommands & NewHelpHandler).
+// Commands without description are not published.
+// This function returns the receiver for convenient chaining.
+func (h *Handler) Describe(description string) *Handler {
+	h.commandInfo().Description = description
+	return h
+}
+
+// DescribeIn sets description of command in specific language (two-letter language code, e. g. "uk").
+// This function returns the receiver for convenient chaining.
+func (h *Handler) DescribeIn(language string, description string) *Handler {
+	info := h.commandInfo()
+	if info.Descriptions == nil {
+		info.Descriptions = make(map[string]string)
+	}
+	info.Descriptions[language] = description
+	return h
+}
+
+// InScope sets scopes where command is displayed, e. g. ScopePrivate or ScopeAdmins.
+// Keep in mind that scopes affect only command menu & help: use filters to restrict who can actually invoke the command.
+// This function returns the receiver for convenient chaining.
+func (h *Handler) InScope(scopes ...CommandScope) *Handler {
+	h.commandInfo().Scopes = scopes
+	return h
+}
+
+// stateScope tells that a handler belongs to a state of a conversation (or to its defaults if isDefault is set).
+type stateScope struct {
+	conversation *Handler
+	state        string
+	isDefault    bool
+}
+
+// walkHandlers calls visit for every handler of this multiplexer, nested multiplexers & conversation states.
+// path contains multiplexers from the root to the one which contains the handler,
+// scopes contains conversation states (from the outermost one) which the handler belongs to.
+func (m *Mux) walkHandlers(path []*Mux, visit func(path []*Mux, scopes []stateScope, h *Handler)) {
+	path = append(path[:len(path):len(path)], m)
+	var walkHandler func(scopes []stateScope, h *Handler)
+	walkHandler = func(scopes []stateScope, h *Handler) {
+		visit(path, scopes, h)
+		if h.states != nil {
+			scopes = scopes[:len(scopes):len(scopes)]
+			states := []string{}
+			for state := range h.states {
+				states = append(states, state)
+			}
+			sort.Strings(states)
+			for _, state := range states {
+				for _, child := range h.states[state] {
+					walkHandler(append(scopes, stateScope{h, state, false}), child)
+				}
+			}
+			for _, child := range h.defaults {
+				walkHandler(append(scopes, stateScope{h, "", true}), child)
+			}
+		}
+	}
+	for _, processor := range m.Processors {
+		switch p := processor.(type) {
+		case *Mux:
+			p.walkHandlers(path, visit)
+		case *Handler:
+			walkHandler(nil, p)
+		}
+	}
+}
+
+// Commands collects described commands from all handlers of this multiplexer, nested multiplexers & conversation states.
+// If the same command is described several times, the first description is used.
+func (m *Mux) Commands() []Command {
+	commands := []Command{}
+	seen := make(map[string]bool)
+	m.walkHandlers(nil, func(path []*Mux, scopes []stateScope, h *Handler) {
+		if h.Command == nil || h.Command.Description == "" {
+			return
+		}
+		for _, name := range h.Command.Names {
+			if seen[name] {
+				continue
+			}
+			seen[name] = true
+			scopes := h.Command.Scopes
+			if len(scopes) == 0 {
+				scopes = []CommandScope{ScopeDefault}
+			}
+			commands = append(commands, Command{name, h.Command.Description, h.Command.Descriptions, scopes})
+		}
+	})
+	return commands
+}
+
+// Requester sends requests to Bot API. It is implemented by *tgbotapi.BotAPI.
+type Requester interface {
+	Request(c tgbotapi.Chattable) (*tgbotapi.APIResponse, error)
+}
+
+func hasScope(command Command, scope CommandScope) bool {
+	for _, s := range command.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// CommandLists returns command lists to publish with setMyCommands, one for each scope & language used by commands.
+//
+// Since Telegram displays commands of the narrowest matching scope only, lists of narrower scopes also include commands
+// of broader scopes: e. g. ScopeAdmins list includes ScopeGroups & ScopeDefault commands.
+// Similarly, localized lists include commands which have no description in that language.
+func (m *Mux) CommandLists() []tgbotapi.SetMyCommandsConfig {
+	commands := m.Commands()
+	usedScopes, languageSet := commandUsage(commands)
+	languages := sortedLanguages(languageSet)
+	configs := []tgbotapi.SetMyCommandsConfig{}
+	for _, scope := range commandScopes {
+		if !usedScopes[scope] {
+			continue
+		}
+		for _, language := range languages {
+			list := []tgbotapi.BotCommand{}
+			for _, command := range commands {
+				visible := hasScope(command, scope)
+				for _, parent := range parentScopes[scope] {
+					visible = visible || hasScope(command, parent)
+				}
+				if visible {
+					list = append(list, tgbotapi.BotCommand{
+						Command:     command.Name,
+						Description: command.LocalizedDescription(language),
+					})
+				}
+			}
+			configs = append(configs, tgbotapi.SetMyCommandsConfig{
+				Commands:     list,
+				Scope:        &tgbotapi.BotCommandScope{Type: string(scope)},
+				LanguageCode: language,
+			})
+		}
+	}
+	return configs
+}
+
+// commandUsage returns scopes & languages (including "" for default language) used by commands.
+func commandUsage(commands []Command) (map[CommandScope]bool, map[string]bool) {
+	scopes := make(map[CommandScope]bool)
+	languages := map[string]bool{"": true}
+	for _, command := range commands {
+		for _, scope := range command.Scopes {
+			scopes[scope] = true
+		}
+		for language := range command.Descriptions {
+			languages[language] = true
+		}
+	}
+	return scopes, languages
+}
+
+func sortedLanguages(languages map[string]bool) []string {
+	result := []string{}
+	for language := range languages {
+		result = append(result, language)
+	}
+	sort.Strings(result)
+	return result
+}
+
+// StaleCommandLists returns command lists to delete with deleteMyCommands: lists of all scopes & languages
+// which are not returned by CommandLists, so commands which were published before do not stay in the menu.
+// Since Telegram does not tell which languages have lists, languages which may have been used before
+// (in addition to languages used by commands) must be passed explicitly.
+func (m *Mux) StaleCommandLists(languages ...string) []tgbotapi.DeleteMyCommandsConfig {
+	usedScopes, usedLanguages := commandUsage(m.Commands())
+	known := make(map[string]bool)
+	for language := range usedLanguages {
+		known[language] = true
+	}
+	for _, language := range languages {
+		known[language] = true
+	}
+	configs := []tgbotapi.DeleteMyCommandsConfig{}
+	for _, scope := range commandScopes {
+		for _, language := range sortedLanguages(known) {
+			if usedScopes[scope] && usedLanguages[language] {
+				continue
+			}
+			configs = append(configs, tgbotapi.DeleteMyCommandsConfig{
+				Scope:        &tgbotapi.BotCommandScope{Type: string(scope)},
+				LanguageCode: language,
+			})
+		}
+	}
+	return configs
+}
+
+// SyncCommands publishes described commands with setMyCommands, once for every scope & language (see CommandLists),
+// and deletes lists of scopes & languages which are no longer used with deleteMyCommands (see StaleCommandLists).
+// languages are passed to StaleCommandLists, e. g. languages which were removed from descriptions.
+func (m *Mux) SyncCommands(bot Requester, languages ...string) error {
+	for _, config := range m.CommandLists() {
+		if _, err := bot.Request(config); err != nil {
+			return err
+		}
+	}
+	for _, config := range m.StaleCommandLists(languages...) {
+		if _, err := bot.Request(config); err != nil {
+			return err
+		}
+	}
+	return nil
+}
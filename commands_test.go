@@ -0,0 +1,99 @@
+package telemux_test
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	tm "github.com/and3rson/telemux/v2"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+type recordingRequester struct {
+	requests []tgbotapi.Chattable
+	err      error
+}
+
+func (r *recordingRequester) Request(c tgbotapi.Chattable) (*tgbotapi.APIResponse, error) {
+	r.requests = append(r.requests, c)
+	return &tgbotapi.APIResponse{Ok: r.err == nil}, r.err
+}
+
+func newCommandsMux() *tm.Mux {
+	return tm.NewMux().
+		AddHandler(tm.NewCommandHandler("start", nil).Describe("Start bot").DescribeIn("uk", "Почати")).
+		AddHandler(tm.NewCommandHandler("undocumented", nil)).
+		AddMux(tm.NewMux().
+			AddHandler(tm.NewCommandHandler("ban kick", nil).Describe("Ban user").InScope(tm.ScopeAdmins)).
+			AddHandler(tm.NewConversationHandler(
+				"settings",
+				tm.NewLocalPersistence(),
+				tm.StateMap{
+					"": {tm.NewCommandHandler("settings", nil).Describe("Settings").InScope(tm.ScopePrivate)},
+					"edit": {
+						tm.NewCommandHandler("save", nil).Describe("Save settings").InScope(tm.ScopePrivate),
+						tm.NewCommandHandler("start", nil).Describe("Duplicate"),
+					},
+				},
+				[]*tm.Handler{tm.NewCommandHandler("cancel", nil).Describe("Cancel").InScope(tm.ScopePrivate)},
+			)),
+		)
+}
+
+func TestMuxCommands(t *testing.T) {
+	names := []string{}
+	for _, command := range newCommandsMux().Commands() {
+		names = append(names, command.Name)
+	}
+	assert(reflect.DeepEqual(names, []string{"start", "ban", "kick", "settings", "save", "cancel"}), t, names)
+	start := newCommandsMux().Commands()[0]
+	assert(start.Description == "Start bot" && start.LocalizedDescription("uk") == "Почати", t, start)
+	assert(start.LocalizedDescription("de") == "Start bot", t)
+	assert(reflect.DeepEqual(start.Scopes, []tm.CommandScope{tm.ScopeDefault}), t, start.Scopes)
+}
+
+func TestSyncCommands(t *testing.T) {
+	requester := &recordingRequester{}
+	assert(newCommandsMux().SyncCommands(requester, "de") == nil, t)
+
+	lists := map[string][]string{}
+	deleted := []string{}
+	for _, request := range requester.requests {
+		switch config := request.(type) {
+		case tgbotapi.SetMyCommandsConfig:
+			key := config.Scope.Type + ":" + config.LanguageCode
+			for _, command := range config.Commands {
+				lists[key] = append(lists[key], command.Command+"="+command.Description)
+			}
+		case tgbotapi.DeleteMyCommandsConfig:
+			deleted = append(deleted, config.Scope.Type+":"+config.LanguageCode)
+		}
+	}
+	expected := map[string][]string{
+		"default:":                   {"start=Start bot"},
+		"default:uk":                 {"start=Почати"},
+		"all_private_chats:":         {"start=Start bot", "settings=Settings", "save=Save settings", "cancel=Cancel"},
+		"all_private_chats:uk":       {"start=Почати", "settings=Settings", "save=Save settings", "cancel=Cancel"},
+		"all_chat_administrators:":   {"start=Start bot", "ban=Ban user", "kick=Ban user"},
+		"all_chat_administrators:uk": {"start=Почати", "ban=Ban user", "kick=Ban user"},
+	}
+	assert(reflect.DeepEqual(lists, expected), t, lists)
+	// Unused scopes & languages are deleted
+	expectedDeleted := []string{
+		"default:de", "all_private_chats:de", "all_group_chats:", "all_group_chats:de", "all_group_chats:uk",
+		"all_chat_administrators:de",
+	}
+	assert(reflect.DeepEqual(deleted, expectedDeleted), t, deleted)
+	assert(len(requester.requests) == 12, t, len(requester.requests))
+
+	// Lists of removed scopes are deleted when all their commands are removed
+	requester = &recordingRequester{}
+	mux := tm.NewMux().AddHandler(tm.NewCommandHandler("start", nil).Describe("Start bot"))
+	assert(mux.SyncCommands(requester, "uk") == nil, t)
+	assert(len(requester.requests) == 8, t, len(requester.requests))
+	assert(len(mux.StaleCommandLists()) == 3, t, mux.StaleCommandLists())
+
+	failing := &recordingRequester{err: errors.New("boom")}
+	assert(newCommandsMux().SyncCommands(failing) != nil, t)
+	assert(len(failing.requests) == 1, t)
+}
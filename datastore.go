@@ -0,0 +1,181 @@
+package telemux
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// DataScope defines what the data is bound to: a user, a chat or a bot.
+type DataScope string
+
+const (
+	// UserScope is used for data bound to a user, e. g. user's language preference.
+	UserScope DataScope = "user"
+	// ChatScope is used for data bound to a chat, e. g. chat settings.
+	ChatScope DataScope = "chat"
+	// BotScope is used for data shared across all users & chats of a bot.
+	BotScope DataScope = "bot"
+)
+
+// DataKey identifies data of a single user, chat or bot.
+type DataKey struct {
+	Scope DataScope `gorm:"primaryKey"`
+	ID    int64     `gorm:"primaryKey;autoIncrement:false"`
+}
+
+// String returns a string in form "SCOPE:ID".
+func (k DataKey) String() string {
+	return fmt.Sprintf("%s:%d", k.Scope, k.ID)
+}
+
+// MarshalText marshals data key for use in map keys
+func (k DataKey) MarshalText() ([]byte, error) {
+	return []byte(k.String()), nil
+}
+
+// UnmarshalText unmarshals data key from "SCOPE:ID" string
+func (k *DataKey) UnmarshalText(b []byte) error {
+	parts := strings.Split(string(b), ":")
+	if len(parts) != 2 {
+		return fmt.Errorf("invalid data key: %s", b)
+	}
+	id, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil {
+		return err
+	}
+	k.Scope = DataScope(parts[0])
+	k.ID = id
+	return nil
+}
+
+// DataStore tells telemux where to store & how to retrieve data which is not bound to conversations,
+// e. g. user's language preference or chat settings.
+//
+// DataStore is registered with Mux.SetDataStore and is available in every handler
+// via Update.UserData(), Update.ChatData() & Update.BotData().
+type DataStore interface {
+	GetData(key DataKey) Data
+	SetData(key DataKey, data Data)
+}
+
+// DataContext allows handler to get/set data of a single user, chat or bot.
+type DataContext struct {
+	Store DataStore
+	Key   DataKey
+}
+
+// GetData returns stored data.
+func (c *DataContext) GetData() Data {
+	return c.Store.GetData(c.Key)
+}
+
+// SetData updates stored data.
+func (c *DataContext) SetData(data Data) {
+	c.Store.SetData(c.Key, data)
+}
+
+// ClearData clears stored data.
+func (c *DataContext) ClearData() {
+	c.Store.SetData(c.Key, make(Data))
+}
+
+// PutDataValue is a shortcut to insert value into stored data in one line.
+func (c *DataContext) PutDataValue(key string, value interface{}) {
+	data := c.GetData()
+	data[key] = value
+	c.SetData(data)
+}
+
+// LocalDataStore is an implementation of DataStore.
+// It stores data in memory.
+//
+// All data in this implementation is lost if an application is restarted.
+type LocalDataStore struct {
+	Data map[DataKey]Data
+}
+
+// NewLocalDataStore creates new instance of LocalDataStore.
+func NewLocalDataStore() *LocalDataStore {
+	return &LocalDataStore{
+		make(map[DataKey]Data),
+	}
+}
+
+// GetData returns data from memory
+func (s *LocalDataStore) GetData(key DataKey) Data {
+	data, ok := s.Data[key]
+	if !ok {
+		s.Data[key] = make(Data)
+		return s.Data[key]
+	}
+	return data
+}
+
+// SetData stores data in memory
+func (s *LocalDataStore) SetData(key DataKey, data Data) {
+	s.Data[key] = data
+}
+
+// FileDataStore is an implementation of DataStore.
+// It stores data in file.
+type FileDataStore struct {
+	mutex    *sync.Mutex
+	Filename string
+}
+
+// NewFileDataStore creates new instance of FileDataStore.
+func NewFileDataStore(filename string) *FileDataStore {
+	return &FileDataStore{
+		&sync.Mutex{},
+		filename,
+	}
+}
+
+func (s *FileDataStore) readContent() map[DataKey]Data {
+	content := make(map[DataKey]Data)
+	data, err := ioutil.ReadFile(s.Filename)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return content
+		}
+		panic(err)
+	}
+	json.Unmarshal(data, &content)
+	return content
+}
+
+func (s *FileDataStore) writeContent(content map[DataKey]Data) {
+	data, err := json.Marshal(content)
+	if err != nil {
+		panic(err)
+	}
+	err = ioutil.WriteFile(s.Filename, data, 0644)
+	if err != nil {
+		panic(err)
+	}
+}
+
+// GetData reads data from file
+func (s *FileDataStore) GetData(key DataKey) Data {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	data, ok := s.readContent()[key]
+	if !ok {
+		return make(Data)
+	}
+	return data
+}
+
+// SetData writes data to file
+func (s *FileDataStore) SetData(key DataKey, data Data) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	content := s.readContent()
+	content[key] = data
+	s.writeContent(content)
+}
@@ -0,0 +1,88 @@
+package telemux_test
+
+import (
+	"io/ioutil"
+	"os"
+	"reflect"
+	"testing"
+
+	tm "github.com/and3rson/telemux/v2"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+func TestDataKey(t *testing.T) {
+	k := tm.DataKey{tm.UserScope, 13}
+	text, _ := k.MarshalText()
+	assert(string(text) == "user:13", t)
+
+	assert(k.UnmarshalText([]byte("chat:-42")) == nil, t)
+	assert(reflect.DeepEqual(k, tm.DataKey{tm.ChatScope, -42}), t)
+
+	assert(k.UnmarshalText([]byte("chat")) != nil, t)
+	assert(k.UnmarshalText([]byte("chat:aa")) != nil, t)
+}
+
+func TestFileDataStore(t *testing.T) {
+	f, err := ioutil.TempFile("", "telemux_datastore")
+	if err != nil {
+		t.Error("Failed to create temporary file")
+	}
+	f.Close()
+	os.Remove(f.Name())
+	defer os.Remove(f.Name())
+
+	s := tm.NewFileDataStore(f.Name())
+	userKey := tm.DataKey{tm.UserScope, 1}
+	chatKey := tm.DataKey{tm.ChatScope, 1}
+	assert(reflect.DeepEqual(s.GetData(userKey), tm.Data{}), t)
+	s.SetData(userKey, tm.Data{"lang": "uk"})
+	assert(reflect.DeepEqual(s.GetData(userKey), tm.Data{"lang": "uk"}), t)
+	assert(reflect.DeepEqual(s.GetData(chatKey), tm.Data{}), t)
+}
+
+func TestUpdateData(t *testing.T) {
+	NewTGUpdate := func(userID, chatID int64) tgbotapi.Update {
+		u := tgbotapi.Update{}
+		u.Message = &tgbotapi.Message{
+			From: &tgbotapi.User{ID: userID},
+			Chat: &tgbotapi.Chat{ID: chatID},
+		}
+		return u
+	}
+
+	store := tm.NewLocalDataStore()
+	nestedStore := tm.NewLocalDataStore()
+	var hasData bool
+	mux := tm.NewMux().
+		SetDataStore(store).
+		AddMux(tm.NewMux().
+			SetDataStore(nestedStore).
+			SetGlobalFilter(func(u *tm.Update) bool { return u.EffectiveChat().ID == 0 }).
+			AddHandler(tm.NewHandler(nil, func(u *tm.Update) {
+				u.UserData().PutDataValue("nested", true)
+			})),
+		).
+		AddHandler(tm.NewHandler(nil, func(u *tm.Update) {
+			_, hasData = u.UserData().GetData()["lang"]
+			u.UserData().PutDataValue("lang", "uk")
+			u.ChatData().PutDataValue("count", len(u.ChatData().GetData())+1)
+			u.BotData().PutDataValue("seen", true)
+		}))
+
+	mux.Dispatch(nil, NewTGUpdate(1, 2))
+	assert(!hasData, t)
+	mux.Dispatch(nil, NewTGUpdate(1, 3))
+	assert(hasData, t)
+	assert(reflect.DeepEqual(store.GetData(tm.DataKey{tm.UserScope, 1}), tm.Data{"lang": "uk"}), t)
+	assert(reflect.DeepEqual(store.GetData(tm.DataKey{tm.ChatScope, 2}), tm.Data{"count": 1}), t)
+	assert(reflect.DeepEqual(store.GetData(tm.DataKey{tm.BotScope, 0}), tm.Data{"seen": true}), t)
+
+	mux.Dispatch(nil, NewTGUpdate(1, 0))
+	assert(reflect.DeepEqual(nestedStore.GetData(tm.DataKey{tm.UserScope, 1}), tm.Data{"nested": true}), t)
+	assert(reflect.DeepEqual(store.GetData(tm.DataKey{tm.UserScope, 1}), tm.Data{"lang": "uk"}), t)
+
+	u := &tm.Update{}
+	assert(u.UserData() == nil, t)
+	assert(u.ChatData() == nil, t)
+	assert(u.BotData() == nil, t)
+}
@@ -0,0 +1,108 @@
+package telemux
+
+import (
+	"strings"
+	"sync"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+type debounceBuffer struct {
+	update   *Update
+	messages []*tgbotapi.Message
+	timer    *time.Timer
+}
+
+// debounceKey returns persistence key of current conversation or, outside of conversations, a key of user & chat.
+func debounceKey(u *Update) PersistenceKey {
+	if u.PersistenceContext != nil {
+		return u.PersistenceContext.PK
+	}
+	pk := PersistenceKey{}
+	if user := u.EffectiveUser(); user != nil {
+		pk.UserID = user.ID
+	}
+	if chat := u.EffectiveChat(); chat != nil {
+		pk.ChatID = chat.ID
+	}
+	return pk
+}
+
+// NewDebounceHandler creates a handler which coalesces messages sent by the same user in the same chat
+// (or in the same conversation, see PersistenceKey) in rapid succession.
+//
+// Messages are buffered until no new messages arrive for window and then handle is called once
+// with the last update of the batch. u.Context["messages"] is set to all messages of the batch ([]*tgbotapi.Message)
+// and u.Context["text"] is set to their texts (or captions) joined with newlines.
+//
+// If a command arrives while messages are buffered, the batch is discarded and the command is left to other handlers,
+// so debounce handler should be added before command handlers.
+//
+// Handle is called after the window in the same way as in NewMediaGroupHandlerWithQuietPeriod,
+// with u.PersistenceContext of the last update.
+func NewDebounceHandler(window time.Duration, filter FilterFunc, handle HandleFunc) *Handler {
+	var mutex sync.Mutex
+	buffers := make(map[PersistenceKey]*debounceBuffer)
+
+	flush := func(key PersistenceKey, buffer *debounceBuffer) {
+		mutex.Lock()
+		if buffers[key] != buffer {
+			// Already flushed or cancelled
+			mutex.Unlock()
+			return
+		}
+		delete(buffers, key)
+		mutex.Unlock()
+		texts := []string{}
+		for _, message := range buffer.messages {
+			if text := message.Text; text != "" {
+				texts = append(texts, text)
+			} else if message.Caption != "" {
+				texts = append(texts, message.Caption)
+			}
+		}
+		u := buffer.update
+		u.setContext("messages", buffer.messages)
+		u.setContext("text", strings.Join(texts, "\n"))
+		u.runDeferred("debounce", handle)
+	}
+
+	isCommand := IsAnyCommandMessage()
+	newFilter := And(Not(IsCallbackQuery()), func(u *Update) bool {
+		if u.EffectiveMessage() == nil {
+			return false
+		}
+		if isCommand(u) {
+			if !u.probing {
+				key := debounceKey(u)
+				mutex.Lock()
+				if buffer, ok := buffers[key]; ok {
+					buffer.timer.Stop()
+					delete(buffers, key)
+				}
+				mutex.Unlock()
+			}
+			return false
+		}
+		return true
+	})
+	if filter != nil {
+		newFilter = And(newFilter, filter)
+	}
+	return NewHandler(newFilter, func(u *Update) {
+		key := debounceKey(u)
+		mutex.Lock()
+		defer mutex.Unlock()
+		buffer, ok := buffers[key]
+		if !ok {
+			buffer = &debounceBuffer{}
+			buffer.timer = time.AfterFunc(window, func() { flush(key, buffer) })
+			buffers[key] = buffer
+		} else {
+			buffer.timer.Reset(window)
+		}
+		buffer.update = u.detach()
+		buffer.messages = append(buffer.messages, u.EffectiveMessage())
+	})
+}
@@ -0,0 +1,123 @@
+package telemux_test
+
+import (
+	"testing"
+	"time"
+
+	tm "github.com/and3rson/telemux/v2"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+func TestDebounceHandler(t *testing.T) {
+	NewTGUpdate := func(id int, userID int64, text string) tgbotapi.Update {
+		u := tgbotapi.Update{}
+		u.Message = &tgbotapi.Message{
+			MessageID: id,
+			Text:      text,
+			From:      &tgbotapi.User{ID: userID},
+			Chat:      &tgbotapi.Chat{ID: 1},
+		}
+		return u
+	}
+
+	batches := make(chan tm.Map, 10)
+	commands := 0
+	mux := tm.NewMux().
+		AddHandler(tm.NewDebounceHandler(50*time.Millisecond, nil, func(u *tm.Update) {
+			batches <- tm.Map{
+				"id":       u.Message.MessageID,
+				"text":     u.Context["text"],
+				"messages": len(u.Context["messages"].([]*tgbotapi.Message)),
+			}
+		})).
+		AddHandler(tm.NewCommandHandler("reset", nil, func(u *tm.Update) {
+			commands++
+		}))
+
+	assert(mux.Dispatch(nil, NewTGUpdate(1, 1, "Hello")), t)
+	assert(mux.Dispatch(nil, NewTGUpdate(2, 2, "Other user")), t)
+	assert(mux.Dispatch(nil, NewTGUpdate(3, 1, "How are you?")), t)
+	select {
+	case <-batches:
+		t.Error("batch should not be flushed yet")
+	default:
+	}
+
+	received := map[int]tm.Map{}
+	for i := 0; i < 2; i++ {
+		select {
+		case batch := <-batches:
+			received[batch["id"].(int)] = batch
+		case <-time.After(time.Second):
+			t.Fatal("batch was not flushed")
+		}
+	}
+	assert(received[3]["text"] == "Hello\nHow are you?", t, received)
+	assert(received[3]["messages"] == 2, t, received)
+	assert(received[2]["text"] == "Other user", t, received)
+
+	// Command cancels the batch
+	assert(mux.Dispatch(nil, NewTGUpdate(4, 1, "Forget it")), t)
+	assert(mux.Dispatch(nil, NewTGUpdate(5, 1, "/reset")), t)
+	assert(commands == 1, t, commands)
+	select {
+	case batch := <-batches:
+		t.Error("batch should be cancelled", batch)
+	case <-time.After(150 * time.Millisecond):
+	}
+
+	// Deferred handling is not traced
+	traced := make(chan bool, 1)
+	mux = tm.NewMux().SetDebug(true).AddHandler(tm.NewDebounceHandler(10*time.Millisecond, nil, func(u *tm.Update) {
+		traced <- u.Trace != nil
+	}))
+	assert(mux.Dispatch(nil, NewTGUpdate(6, 1, "Traced?")), t)
+	assert(!<-traced, t)
+}
+
+func TestDebounceConversation(t *testing.T) {
+	NewTGUpdate := func(text string) tgbotapi.Update {
+		u := tgbotapi.Update{}
+		u.Message = &tgbotapi.Message{Text: text, From: &tgbotapi.User{ID: 1}, Chat: &tgbotapi.Chat{ID: 1}}
+		return u
+	}
+
+	persistence := tm.NewLocalPersistence()
+	done := make(chan int, 1)
+	mux := tm.NewMux().AddHandler(tm.NewConversationHandler(
+		"ask",
+		persistence,
+		tm.StateMap{
+			"": {tm.NewCommandHandler("ask", nil, func(u *tm.Update) {
+				u.PersistenceContext.SetState("question")
+			})},
+			"question": {tm.NewDebounceHandler(50*time.Millisecond, nil, func(u *tm.Update) {
+				u.PersistenceContext.PutDataValue("question", u.Context["text"])
+				u.PersistenceContext.SetState("")
+				done <- 1
+			})},
+		},
+		nil,
+	))
+
+	assert(mux.Dispatch(nil, NewTGUpdate("/ask")), t)
+	assert(mux.Dispatch(nil, NewTGUpdate("What is")), t)
+	assert(mux.Dispatch(nil, NewTGUpdate("the answer?")), t)
+	// Other users keep using the same persistence while the batch is handled (run with -race)
+	deadline := time.After(time.Second)
+	for userID, waiting := int64(2), true; waiting; userID++ {
+		select {
+		case <-done:
+			waiting = false
+		case <-deadline:
+			t.Fatal("batch was not flushed")
+		default:
+			other := NewTGUpdate("/ask")
+			other.Message.From.ID = userID
+			assert(mux.Dispatch(nil, other), t)
+		}
+	}
+	pk := tm.PersistenceKey{ConversationID: "ask", UserID: 1, ChatID: 1}
+	assert(persistence.GetState(pk) == "", t, persistence.GetState(pk))
+	assert(persistence.GetData(pk)["question"] == "What is\nthe answer?", t, persistence.GetData(pk))
+}
@@ -0,0 +1,122 @@
+package telemux
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// MaxDeepLinkPayload is the maximum length of deep-link payload allowed by Telegram.
+const MaxDeepLinkPayload = 64
+
+// ErrInvalidDeepLinkPayload is returned when deep-link payload contains forbidden characters or is too long.
+var ErrInvalidDeepLinkPayload = errors.New("invalid deep-link payload")
+
+var deepLinkPayloadRegex = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// EncodeDeepLinkPayload encodes arbitrary string with base64url (without padding) to make it usable as deep-link payload.
+// Keep in mind that encoded payload must not exceed MaxDeepLinkPayload characters.
+func EncodeDeepLinkPayload(data string) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(data))
+}
+
+// DecodeDeepLinkPayload decodes payload encoded with EncodeDeepLinkPayload.
+// Padding is optional. Returns an error if payload is not a valid base64url-encoded UTF-8 string.
+func DecodeDeepLinkPayload(payload string) (string, error) {
+	data, err := base64.RawURLEncoding.Strict().DecodeString(strings.TrimRight(payload, "="))
+	if err != nil {
+		return "", err
+	}
+	if !utf8.Valid(data) {
+		return "", fmt.Errorf("decoded payload is not a valid UTF-8 string")
+	}
+	return string(data), nil
+}
+
+func deepLink(bot *tgbotapi.BotAPI, parameter string, payload string) (string, error) {
+	if len(payload) > MaxDeepLinkPayload || !deepLinkPayloadRegex.MatchString(payload) {
+		return "", fmt.Errorf("%w %q, use EncodeDeepLinkPayload to encode arbitrary data", ErrInvalidDeepLinkPayload, payload)
+	}
+	return fmt.Sprintf("https://t.me/%s?%s=%s", bot.Self.UserName, parameter, payload), nil
+}
+
+// DeepLink generates a link which starts private chat with the bot and sends "/start PAYLOAD" to it.
+// Payload may contain only A-Z, a-z, 0-9, "_" & "-" and must not exceed MaxDeepLinkPayload characters,
+// otherwise ErrInvalidDeepLinkPayload is returned. Use EncodeDeepLinkPayload to pass arbitrary data.
+func DeepLink(bot *tgbotapi.BotAPI, payload string) (string, error) {
+	return deepLink(bot, "start", payload)
+}
+
+// MustDeepLink is like DeepLink but panics if payload is invalid.
+// It simplifies generation of links with constant payloads.
+func MustDeepLink(bot *tgbotapi.BotAPI, payload string) string {
+	link, err := DeepLink(bot, payload)
+	if err != nil {
+		panic(err)
+	}
+	return link
+}
+
+// GroupDeepLink generates a link which adds the bot to a group and sends "/start@bot_name PAYLOAD" to it.
+// Payload restrictions are the same as in DeepLink.
+func GroupDeepLink(bot *tgbotapi.BotAPI, payload string) (string, error) {
+	return deepLink(bot, "startgroup", payload)
+}
+
+// MustGroupDeepLink is like GroupDeepLink but panics if payload is invalid.
+func MustGroupDeepLink(bot *tgbotapi.BotAPI, payload string) string {
+	link, err := GroupDeepLink(bot, payload)
+	if err != nil {
+		panic(err)
+	}
+	return link
+}
+
+// deepLinkPayload returns argument of "/start" command.
+func deepLinkPayload(u *Update) string {
+	parts := strings.SplitN(u.Message.Text, " ", 2)
+	if len(parts) < 2 {
+		return ""
+	}
+	return strings.TrimSpace(parts[1])
+}
+
+// NewDeepLinkHandler creates a handler for "/start PAYLOAD" commands sent when user follows a deep link
+// (see DeepLink & GroupDeepLink) and payload matches the pattern as regexp.
+//
+// It populates u.Context["payload"] with raw payload, u.Context["exp"] with compiled regexp,
+// u.Context["matches"] with a slice of matches and u.Context["groups"] with a map of named capture groups.
+// If payload is a valid base64url-encoded string (see EncodeDeepLinkPayload), u.Context["decoded"] is set to decoded value.
+// Keep in mind that some plain payloads are valid base64url strings too, so do not rely on presence of "decoded" alone.
+//
+// For example, pattern `^ref_(?P<code>\w+)$` will set u.Context["groups"] to map[string]string{"code": "42"} for "/start ref_42".
+// "/start" without payload is not handled, so make sure to add a regular "start" command handler after deep-link handlers.
+func NewDeepLinkHandler(pattern string, filter FilterFunc, handles ...HandleFunc) *Handler {
+	exp := regexp.MustCompile(pattern)
+	newFilter := And(IsCommandMessage("start"), func(u *Update) bool {
+		payload := deepLinkPayload(u)
+		return payload != "" && exp.MatchString(payload)
+	})
+	if filter != nil {
+		newFilter = And(newFilter, filter)
+	}
+	handles = append([]HandleFunc{
+		func(u *Update) {
+			payload := deepLinkPayload(u)
+			matches := exp.FindStringSubmatch(payload)
+			u.Context["payload"] = payload
+			u.Context["exp"] = exp
+			u.Context["matches"] = matches
+			u.Context["groups"] = namedGroups(exp, matches)
+			if decoded, err := DecodeDeepLinkPayload(payload); err == nil {
+				u.Context["decoded"] = decoded
+			}
+		},
+	}, handles...)
+	return NewMessageHandler(newFilter, handles...)
+}
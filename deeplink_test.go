@@ -0,0 +1,70 @@
+package telemux_test
+
+import (
+	"errors"
+	"testing"
+
+	tm "github.com/and3rson/telemux/v2"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+func TestDeepLinkHandler(t *testing.T) {
+	bot := &tgbotapi.BotAPI{}
+	bot.Self.UserName = "test_bot"
+	NewTGUpdate := func(text string) tgbotapi.Update {
+		u := tgbotapi.Update{}
+		u.Message = &tgbotapi.Message{Text: text, Chat: &tgbotapi.Chat{}}
+		return u
+	}
+
+	var context tm.Map
+	mux := tm.NewMux().
+		AddHandler(tm.NewDeepLinkHandler(`^ref_(?P<code>\w+)$`, nil, func(u *tm.Update) { context = u.Context })).
+		AddHandler(tm.NewDeepLinkHandler(``, nil, func(u *tm.Update) { context = u.Context }))
+
+	assert(mux.Dispatch(bot, NewTGUpdate("/start ref_42")), t)
+	assert(context["payload"] == "ref_42", t, context)
+	assert(context["groups"].(map[string]string)["code"] == "42", t)
+	_, decoded := context["decoded"]
+	assert(!decoded, t, context)
+
+	payload := tm.EncodeDeepLinkPayload("item:Привіт")
+	assert(mux.Dispatch(bot, NewTGUpdate("/start@test_bot "+payload)), t)
+	assert(context["payload"] == payload, t, context)
+	assert(context["decoded"] == "item:Привіт", t, context)
+
+	assert(!mux.Dispatch(bot, NewTGUpdate("/start")), t)
+	assert(!mux.Dispatch(bot, NewTGUpdate("/start@other_bot ref_42")), t)
+	assert(!mux.Dispatch(bot, NewTGUpdate("/help ref_42")), t)
+}
+
+func TestDeepLinkPayload(t *testing.T) {
+	bot := &tgbotapi.BotAPI{}
+	bot.Self.UserName = "test_bot"
+	link, err := tm.DeepLink(bot, "ref_42")
+	assert(err == nil && link == "https://t.me/test_bot?start=ref_42", t, link, err)
+	link, err = tm.GroupDeepLink(bot, "ref_42")
+	assert(err == nil && link == "https://t.me/test_bot?startgroup=ref_42", t, link, err)
+
+	payload := tm.EncodeDeepLinkPayload("a/b?c")
+	assert(tm.MustDeepLink(bot, payload) == "https://t.me/test_bot?start=YS9iP2M", t, payload)
+	decoded, err := tm.DecodeDeepLinkPayload(payload + "=")
+	assert(err == nil && decoded == "a/b?c", t, decoded, err)
+	_, err = tm.DecodeDeepLinkPayload("a")
+	assert(err != nil, t)
+	_, err = tm.DecodeDeepLinkPayload(tm.EncodeDeepLinkPayload("\xff"))
+	assert(err != nil, t)
+
+	for _, invalid := range []string{"", "a b", "a/b", string(make([]byte, 65))} {
+		_, err := tm.DeepLink(bot, invalid)
+		assert(errors.Is(err, tm.ErrInvalidDeepLinkPayload), t, invalid, err)
+		_, err = tm.GroupDeepLink(bot, invalid)
+		assert(errors.Is(err, tm.ErrInvalidDeepLinkPayload), t, invalid, err)
+		func() {
+			defer func() {
+				assert(recover() != nil, t, invalid)
+			}()
+			tm.MustDeepLink(bot, invalid)
+		}()
+	}
+}
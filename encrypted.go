@@ -0,0 +1,250 @@
+package telemux
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+const encryptedPrefix = "$encrypted"
+
+// ErrUnknownKey is returned when data was encrypted with a key which is not present in Keyring.
+var ErrUnknownKey = errors.New("unknown encryption key")
+
+// Keyring contains AES keys used by EncryptedPersistence.
+// Primary key is used to encrypt new data, while all keys are used to decrypt existing data.
+// This allows to rotate keys: add a new key, make it primary & keep the old one until all data is re-encrypted
+// (see EncryptedPersistence.Rotate). Keyring is safe for concurrent use, so keys can be rotated at runtime.
+type Keyring struct {
+	mutex   sync.RWMutex
+	aeads   map[string]cipher.AEAD
+	primary string
+}
+
+// NewKeyring creates a keyring with a primary key.
+// Key must be 16, 24 or 32 bytes long to select AES-128, AES-192 or AES-256.
+func NewKeyring(primaryID string, primaryKey []byte) (*Keyring, error) {
+	k := &Keyring{aeads: make(map[string]cipher.AEAD)}
+	if err := k.AddKey(primaryID, primaryKey); err != nil {
+		return nil, err
+	}
+	k.primary = primaryID
+	return k, nil
+}
+
+// AddKey adds a key to the keyring. Key ID must not contain colons (":").
+func (k *Keyring) AddKey(id string, key []byte) error {
+	if id == "" || strings.Contains(id, ":") {
+		return fmt.Errorf("invalid key ID: %q", id)
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return err
+	}
+	k.mutex.Lock()
+	defer k.mutex.Unlock()
+	k.aeads[id] = aead
+	return nil
+}
+
+// SetPrimary selects a key which will be used to encrypt new data.
+func (k *Keyring) SetPrimary(id string) error {
+	k.mutex.Lock()
+	defer k.mutex.Unlock()
+	if _, ok := k.aeads[id]; !ok {
+		return ErrUnknownKey
+	}
+	k.primary = id
+	return nil
+}
+
+// Primary returns ID of the primary key.
+func (k *Keyring) Primary() string {
+	k.mutex.RLock()
+	defer k.mutex.RUnlock()
+	return k.primary
+}
+
+// Encrypt encrypts plaintext with primary key and returns "KEY_ID:BASE64_CIPHERTEXT" string.
+// additionalData is authenticated but not encrypted.
+func (k *Keyring) Encrypt(plaintext, additionalData []byte) (string, error) {
+	k.mutex.RLock()
+	primary := k.primary
+	aead := k.aeads[primary]
+	k.mutex.RUnlock()
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	sealed := aead.Seal(nonce, nonce, plaintext, additionalData)
+	return primary + ":" + base64.RawStdEncoding.EncodeToString(sealed), nil
+}
+
+// Decrypt decrypts "KEY_ID:BASE64_CIPHERTEXT" string produced by Encrypt.
+func (k *Keyring) Decrypt(encrypted string, additionalData []byte) ([]byte, error) {
+	parts := strings.SplitN(encrypted, ":", 2)
+	if len(parts) != 2 {
+		return nil, errors.New("malformed ciphertext")
+	}
+	k.mutex.RLock()
+	aead, ok := k.aeads[parts[0]]
+	k.mutex.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownKey, parts[0])
+	}
+	sealed, err := base64.RawStdEncoding.DecodeString(parts[1])
+	if err != nil {
+		return nil, err
+	}
+	if len(sealed) < aead.NonceSize() {
+		return nil, errors.New("malformed ciphertext")
+	}
+	return aead.Open(nil, sealed[:aead.NonceSize()], sealed[aead.NonceSize():], additionalData)
+}
+
+// EncryptedPersistence is a decorator which encrypts conversation data (and optionally states)
+// with AES-GCM before passing it to another persistence.
+//
+// Encrypted values are bound to their PersistenceKey, so they cannot be copied between conversations.
+// Values which were stored before encryption was enabled are read as plaintext and are encrypted on next write.
+//
+// Since data is serialized as JSON, numbers are decoded as float64 (same as with FilePersistence).
+//
+// Errors (e. g. tampered data or unknown key) cause a panic which can be handled with Mux.SetRecover.
+type EncryptedPersistence struct {
+	Inner   ConversationPersistence
+	Keyring *Keyring
+	// PlaintextStates tells persistence to store states unencrypted, e. g. to allow querying them in the database.
+	PlaintextStates bool
+}
+
+// NewEncryptedPersistence creates new instance of EncryptedPersistence.
+func NewEncryptedPersistence(inner ConversationPersistence, keyring *Keyring) *EncryptedPersistence {
+	return &EncryptedPersistence{inner, keyring, false}
+}
+
+// SetPlaintextStates tells persistence whether to store states unencrypted.
+// This function returns the receiver for convenient chaining.
+func (p *EncryptedPersistence) SetPlaintextStates(plaintext bool) *EncryptedPersistence {
+	p.PlaintextStates = plaintext
+	return p
+}
+
+// GetState decrypts conversation state
+func (p *EncryptedPersistence) GetState(pk PersistenceKey) string {
+	state, err := p.getState(pk)
+	if err != nil {
+		panic(err)
+	}
+	return state
+}
+
+func (p *EncryptedPersistence) getState(pk PersistenceKey) (string, error) {
+	state := p.Inner.GetState(pk)
+	if !strings.HasPrefix(state, encryptedPrefix+":") {
+		return state, nil
+	}
+	plaintext, err := p.Keyring.Decrypt(strings.TrimPrefix(state, encryptedPrefix+":"), []byte("state:"+pk.String()))
+	if err != nil {
+		return "", err
+	}
+	return string(plaintext), nil
+}
+
+// SetState encrypts conversation state unless PlaintextStates is set
+func (p *EncryptedPersistence) SetState(pk PersistenceKey, state string) {
+	if p.PlaintextStates || state == "" {
+		p.Inner.SetState(pk, state)
+		return
+	}
+	encrypted, err := p.Keyring.Encrypt([]byte(state), []byte("state:"+pk.String()))
+	if err != nil {
+		panic(err)
+	}
+	p.Inner.SetState(pk, encryptedPrefix+":"+encrypted)
+}
+
+// GetData decrypts conversation data
+func (p *EncryptedPersistence) GetData(pk PersistenceKey) Data {
+	data, err := p.getData(pk)
+	if err != nil {
+		panic(err)
+	}
+	return data
+}
+
+func (p *EncryptedPersistence) getData(pk PersistenceKey) (Data, error) {
+	data := p.Inner.GetData(pk)
+	encrypted, ok := data[encryptedPrefix].(string)
+	if !ok {
+		return data, nil
+	}
+	plaintext, err := p.Keyring.Decrypt(encrypted, []byte("data:"+pk.String()))
+	if err != nil {
+		return nil, err
+	}
+	decrypted := make(Data)
+	if err := json.Unmarshal(plaintext, &decrypted); err != nil {
+		return nil, err
+	}
+	return decrypted, nil
+}
+
+// SetData encrypts conversation data
+func (p *EncryptedPersistence) SetData(pk PersistenceKey, data Data) {
+	plaintext, err := json.Marshal(data)
+	if err != nil {
+		panic(err)
+	}
+	encrypted, err := p.Keyring.Encrypt(plaintext, []byte("data:"+pk.String()))
+	if err != nil {
+		panic(err)
+	}
+	p.Inner.SetData(pk, Data{encryptedPrefix: encrypted})
+}
+
+// Rotate re-encrypts all conversations with the primary key.
+// It also encrypts conversations which were stored before encryption was enabled.
+// Inner persistence must implement Iterable interface.
+func (p *EncryptedPersistence) Rotate() error {
+	iterable, ok := asIterable(p.Inner)
+	if !ok {
+		return ErrNotIterable
+	}
+	keys, err := iterable.Keys()
+	if err != nil {
+		return err
+	}
+	for _, pk := range keys {
+		state, err := p.getState(pk)
+		if err != nil {
+			return fmt.Errorf("%s: %w", pk, err)
+		}
+		data, err := p.getData(pk)
+		if err != nil {
+			return fmt.Errorf("%s: %w", pk, err)
+		}
+		p.SetState(pk, state)
+		p.SetData(pk, data)
+	}
+	return nil
+}
+
+func (p *EncryptedPersistence) inner() ConversationPersistence {
+	return p.Inner
+}
+
+// Keys returns keys of inner persistence. Inner persistence must implement Iterable interface.
+func (p *EncryptedPersistence) Keys() ([]PersistenceKey, error) {
+	return innerKeys(p.Inner)
+}
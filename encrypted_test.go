@@ -0,0 +1,99 @@
+package telemux_test
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	tm "github.com/and3rson/telemux/v2"
+)
+
+func TestEncryptedPersistence(t *testing.T) {
+	keyring, err := tm.NewKeyring("k1", bytes.Repeat([]byte{1}, 32))
+	assert(err == nil, t, err)
+	inner := tm.NewLocalPersistence()
+	p := tm.NewEncryptedPersistence(inner, keyring)
+	pk := tm.PersistenceKey{"foo", 13, 37}
+
+	assert(p.GetState(pk) == "", t)
+	assert(reflect.DeepEqual(p.GetData(pk), tm.Data{}), t)
+
+	p.SetState(pk, "ask_phone")
+	p.SetData(pk, tm.Data{"phone": "+380000000000"})
+	assert(p.GetState(pk) == "ask_phone", t)
+	assert(reflect.DeepEqual(p.GetData(pk), tm.Data{"phone": "+380000000000"}), t)
+	assert(strings.HasPrefix(inner.GetState(pk), "$encrypted:k1:"), t, inner.GetState(pk))
+	assert(!strings.Contains(inner.GetData(pk)["$encrypted"].(string), "380"), t)
+
+	p.SetPlaintextStates(true)
+	p.SetState(pk, "ask_address")
+	assert(inner.GetState(pk) == "ask_address", t)
+	assert(p.GetState(pk) == "ask_address", t)
+
+	// Encrypted values are bound to persistence key
+	other := tm.PersistenceKey{"foo", 13, 38}
+	inner.SetData(other, inner.GetData(pk))
+	func() {
+		defer func() { assert(recover() != nil, t, "Expected panic") }()
+		p.GetData(other)
+	}()
+}
+
+func TestEncryptedPersistenceRotate(t *testing.T) {
+	keyring, _ := tm.NewKeyring("k1", bytes.Repeat([]byte{1}, 16))
+	inner := tm.NewLocalPersistence()
+	p := tm.NewEncryptedPersistence(inner, keyring)
+	pk1 := tm.PersistenceKey{"foo", 1, 2}
+	pk2 := tm.PersistenceKey{"foo", 3, 4}
+	// Plaintext data stored before encryption was enabled
+	inner.SetState(pk1, "plain")
+	inner.SetData(pk1, tm.Data{"name": "Foobar"})
+	p.SetState(pk2, "secret")
+
+	assert(keyring.AddKey("k2", bytes.Repeat([]byte{2}, 32)) == nil, t)
+	assert(keyring.SetPrimary("k2") == nil, t)
+	assert(keyring.SetPrimary("k3") == tm.ErrUnknownKey, t)
+	assert(keyring.AddKey("k:3", bytes.Repeat([]byte{3}, 32)) != nil, t)
+	assert(keyring.AddKey("k3", []byte{3}) != nil, t)
+	assert(p.Rotate() == nil, t)
+
+	assert(strings.HasPrefix(inner.GetState(pk1), "$encrypted:k2:"), t)
+	assert(strings.HasPrefix(inner.GetState(pk2), "$encrypted:k2:"), t)
+	assert(strings.HasPrefix(inner.GetData(pk1)["$encrypted"].(string), "k2:"), t)
+	assert(p.GetState(pk1) == "plain", t)
+	assert(p.GetState(pk2) == "secret", t)
+	assert(reflect.DeepEqual(p.GetData(pk1), tm.Data{"name": "Foobar"}), t)
+
+	newKeyring, _ := tm.NewKeyring("k1", bytes.Repeat([]byte{1}, 16))
+	err := tm.NewEncryptedPersistence(inner, newKeyring).Rotate()
+	assert(errors.Is(err, tm.ErrUnknownKey), t, err)
+	assert(tm.NewEncryptedPersistence(nonIterablePersistence{inner}, keyring).Rotate() == tm.ErrNotIterable, t)
+}
+
+func TestKeyringConcurrentRotation(t *testing.T) {
+	keyring, _ := tm.NewKeyring("k0", bytes.Repeat([]byte{1}, 16))
+	done := make(chan bool)
+	go func() {
+		for i := 1; i <= 50; i++ {
+			id := fmt.Sprintf("k%d", i)
+			assert(keyring.AddKey(id, bytes.Repeat([]byte{byte(i)}, 16)) == nil, t)
+			assert(keyring.SetPrimary(id) == nil, t)
+		}
+		close(done)
+	}()
+	for running := true; running; {
+		select {
+		case <-done:
+			running = false
+		default:
+		}
+		encrypted, err := keyring.Encrypt([]byte("secret"), nil)
+		assert(err == nil, t, err)
+		plaintext, err := keyring.Decrypt(encrypted, nil)
+		assert(err == nil && string(plaintext) == "secret", t, err)
+	}
+	assert(keyring.Primary() == "k50", t, keyring.Primary())
+}
@@ -0,0 +1,138 @@
+package telemux
+
+import (
+	"strings"
+	"unicode/utf16"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// Entity is a message entity together with the text it refers to.
+type Entity struct {
+	tgbotapi.MessageEntity
+	// Text is a part of message text (or caption) which is covered by entity.
+	Text string
+}
+
+// EntityText returns a part of text covered by entity.
+// Entity offsets are measured in UTF-16 code units, so they cannot be used to slice Go strings directly.
+func EntityText(text string, entity tgbotapi.MessageEntity) string {
+	encoded := utf16.Encode([]rune(text))
+	start, end := entity.Offset, entity.Offset+entity.Length
+	if start < 0 || entity.Length < 0 || end > len(encoded) {
+		return ""
+	}
+	return string(utf16.Decode(encoded[start:end]))
+}
+
+// byteOffset converts offset in UTF-16 code units (e. g. of entity) to offset in bytes, or returns -1 if it is out of range.
+func byteOffset(text string, offset int) int {
+	units := 0
+	for i, r := range text {
+		if units == offset {
+			return i
+		}
+		units += utf16.RuneLen(r)
+	}
+	if units == offset {
+		return len(text)
+	}
+	return -1
+}
+
+// Entities returns entities of message text & caption along with the text they refer to.
+func Entities(message *tgbotapi.Message) []Entity {
+	if message == nil {
+		return nil
+	}
+	result := []Entity{}
+	for _, entity := range message.Entities {
+		result = append(result, Entity{entity, EntityText(message.Text, entity)})
+	}
+	for _, entity := range message.CaptionEntities {
+		result = append(result, Entity{entity, EntityText(message.Caption, entity)})
+	}
+	return result
+}
+
+// entityFilter creates a filter which passes if at least one entity of effective message is accepted by extract.
+// Values returned by extract are stored in u.Context[key].
+// Callback queries are not matched, since their messages are sent by the bot.
+func entityFilter(key string, extract func(u *Update, entity Entity) (string, bool)) FilterFunc {
+	return func(u *Update) bool {
+		if u.CallbackQuery != nil {
+			return false
+		}
+		values := []string{}
+		for _, entity := range Entities(u.EffectiveMessage()) {
+			if value, ok := extract(u, entity); ok {
+				values = append(values, value)
+			}
+		}
+		if len(values) == 0 {
+			return false
+		}
+		u.setContext(key, values)
+		return true
+	}
+}
+
+// HasEntity filters updates which contain message with entity of specific type in text or caption,
+// e. g. HasEntity("bot_command") or HasEntity("email").
+// It also populates u.Context["entities"] with a slice of strings covered by those entities.
+func HasEntity(entityType string) FilterFunc {
+	return entityFilter("entities", func(u *Update, entity Entity) (string, bool) {
+		return entity.Text, entity.Type == entityType
+	})
+}
+
+// MentionsBot filters updates which contain message that mentions the bot,
+// either with "@bot_username" or with a text mention.
+// It also populates u.Context["mentions"] with a slice of mention texts.
+func MentionsBot() FilterFunc {
+	return entityFilter("mentions", func(u *Update, entity Entity) (string, bool) {
+		if u.Bot == nil {
+			return "", false
+		}
+		switch entity.Type {
+		case "mention":
+			return entity.Text, strings.EqualFold(entity.Text, "@"+u.Bot.Self.UserName)
+		case "text_mention":
+			return entity.Text, entity.User != nil && entity.User.ID == u.Bot.Self.ID
+		}
+		return "", false
+	})
+}
+
+// HasURL filters updates which contain message with URL (either plain or a clickable text link).
+// It also populates u.Context["urls"] with a slice of URLs.
+func HasURL() FilterFunc {
+	return entityFilter("urls", func(u *Update, entity Entity) (string, bool) {
+		switch entity.Type {
+		case "url":
+			return entity.Text, true
+		case "text_link":
+			return entity.URL, true
+		}
+		return "", false
+	})
+}
+
+// HasHashtag filters updates which contain message with specific hashtag (case-insensitive, without "#").
+// Empty tag matches any hashtag.
+// It also populates u.Context["hashtags"] with a slice of matching hashtags (without "#").
+func HasHashtag(tag string) FilterFunc {
+	tag = strings.TrimPrefix(tag, "#")
+	return entityFilter("hashtags", func(u *Update, entity Entity) (string, bool) {
+		value := strings.TrimPrefix(entity.Text, "#")
+		return value, entity.Type == "hashtag" && (tag == "" || strings.EqualFold(value, tag))
+	})
+}
+
+// HasCashtag filters updates which contain message with cashtag, e. g. "$USD".
+// It also populates u.Context["cashtags"] with a slice of cashtags (without "$").
+func HasCashtag() FilterFunc {
+	return entityFilter("cashtags", func(u *Update, entity Entity) (string, bool) {
+		return strings.TrimPrefix(entity.Text, "$"), entity.Type == "cashtag"
+	})
+}
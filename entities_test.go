@@ -0,0 +1,81 @@
+package telemux_test
+
+import (
+	"reflect"
+	"testing"
+
+	tm "github.com/and3rson/telemux/v2"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+func TestEntityText(t *testing.T) {
+	// "🙂" takes 2 UTF-16 code units & 4 bytes
+	text := "🙂 hi @testbot"
+	assert(tm.EntityText(text, tgbotapi.MessageEntity{Type: "mention", Offset: 6, Length: 8}) == "@testbot", t)
+	assert(tm.EntityText(text, tgbotapi.MessageEntity{Type: "mention", Offset: 0, Length: 2}) == "🙂", t)
+	assert(tm.EntityText(text, tgbotapi.MessageEntity{Type: "mention", Offset: 6, Length: 9}) == "", t)
+}
+
+func TestEntityFilters(t *testing.T) {
+	NewUpdate := func(message *tgbotapi.Message) *tm.Update {
+		u := &tm.Update{}
+		u.Message = message
+		u.Bot = &tgbotapi.BotAPI{}
+		u.Bot.Self.ID = 42
+		u.Bot.Self.UserName = "testbot"
+		return u
+	}
+
+	u := NewUpdate(&tgbotapi.Message{
+		Text: "Привіт @TestBot, see #News and #news at https://example.com or $USD",
+		Entities: []tgbotapi.MessageEntity{
+			{Type: "mention", Offset: 7, Length: 8},
+			{Type: "hashtag", Offset: 21, Length: 5},
+			{Type: "hashtag", Offset: 31, Length: 5},
+			{Type: "url", Offset: 40, Length: 19},
+			{Type: "cashtag", Offset: 63, Length: 4},
+		},
+	})
+	assert(tm.MentionsBot()(u), t)
+	assert(reflect.DeepEqual(u.Context["mentions"], []string{"@TestBot"}), t, u.Context["mentions"])
+	assert(tm.HasHashtag("#news")(u), t)
+	assert(reflect.DeepEqual(u.Context["hashtags"], []string{"News", "news"}), t, u.Context["hashtags"])
+	assert(!tm.HasHashtag("sports")(u), t)
+	assert(tm.HasURL()(u), t)
+	assert(reflect.DeepEqual(u.Context["urls"], []string{"https://example.com"}), t, u.Context["urls"])
+	assert(tm.HasCashtag()(u), t)
+	assert(reflect.DeepEqual(u.Context["cashtags"], []string{"USD"}), t, u.Context["cashtags"])
+	assert(!tm.HasEntity("email")(u), t)
+
+	u = NewUpdate(&tgbotapi.Message{
+		Caption: "Photo by Bot, visit site or /start",
+		CaptionEntities: []tgbotapi.MessageEntity{
+			{Type: "text_mention", Offset: 9, Length: 3, User: &tgbotapi.User{ID: 42}},
+			{Type: "text_link", Offset: 20, Length: 4, URL: "https://example.org"},
+			{Type: "bot_command", Offset: 28, Length: 6},
+		},
+	})
+	assert(tm.MentionsBot()(u), t)
+	assert(reflect.DeepEqual(u.Context["mentions"], []string{"Bot"}), t, u.Context["mentions"])
+	assert(tm.HasURL()(u), t)
+	assert(reflect.DeepEqual(u.Context["urls"], []string{"https://example.org"}), t, u.Context["urls"])
+	assert(tm.HasEntity("bot_command")(u), t)
+	assert(reflect.DeepEqual(u.Context["entities"], []string{"/start"}), t, u.Context["entities"])
+	assert(!tm.HasHashtag("")(u), t)
+
+	u = NewUpdate(&tgbotapi.Message{
+		Text:     "@otherbot",
+		Entities: []tgbotapi.MessageEntity{{Type: "mention", Offset: 0, Length: 9}},
+	})
+	assert(!tm.MentionsBot()(u), t)
+	assert(!tm.MentionsBot()(&tm.Update{}), t)
+
+	// Message of callback query belongs to the bot
+	u = NewUpdate(nil)
+	u.CallbackQuery = &tgbotapi.CallbackQuery{Message: &tgbotapi.Message{
+		Text:     "https://example.com",
+		Entities: []tgbotapi.MessageEntity{{Type: "url", Offset: 0, Length: 19}},
+	}}
+	assert(!tm.HasURL()(u), t)
+	assert(u.Context["urls"] == nil, t, u.Context["urls"])
+}
@@ -0,0 +1,672 @@
+package telemux
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// ParseError describes a syntax or semantic error in filter expression.
+type ParseError struct {
+	// Pos is a 1-based position (in characters) of the error in expression.
+	Pos int
+	Msg string
+}
+
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("position %d: %s", e.Pos, e.Msg)
+}
+
+// FilterFactory creates a filter from arguments passed to it in filter expression.
+// Arguments are either string, int64, *regexp.Regexp or []interface{}.
+type FilterFactory func(args []interface{}) (FilterFunc, error)
+
+// FieldFunc extracts a value (string or int64) from update for comparisons in filter expressions.
+// Returns false if update has no such value.
+type FieldFunc func(u *Update) (interface{}, bool)
+
+// Registry maps identifiers used in filter expressions to filters & fields.
+type Registry struct {
+	filters map[string]FilterFactory
+	fields  map[string]FieldFunc
+}
+
+// NewRegistry creates an empty registry. See also DefaultRegistry.
+func NewRegistry() *Registry {
+	return &Registry{make(map[string]FilterFactory), make(map[string]FieldFunc)}
+}
+
+// Register adds a filter which accepts arguments, e. g. `command("start")`.
+// This function returns the receiver for convenient chaining.
+func (r *Registry) Register(name string, factory FilterFactory) *Registry {
+	r.filters[name] = factory
+	return r
+}
+
+// RegisterFilter adds a filter without arguments, e. g. `private`.
+// This function returns the receiver for convenient chaining.
+func (r *Registry) RegisterFilter(name string, filter FilterFunc) *Registry {
+	return r.Register(name, func(args []interface{}) (FilterFunc, error) {
+		if len(args) != 0 {
+			return nil, fmt.Errorf("%s does not accept arguments", name)
+		}
+		return filter, nil
+	})
+}
+
+// RegisterField adds a field which can be compared with values, e. g. `user in [1, 2]` or `text ~ /^hi/i`.
+// This function returns the receiver for convenient chaining.
+func (r *Registry) RegisterField(name string, field FieldFunc) *Registry {
+	r.fields[name] = field
+	return r
+}
+
+func stringArgs(name string, args []interface{}) ([]string, error) {
+	result := []string{}
+	for _, arg := range args {
+		s, ok := arg.(string)
+		if !ok {
+			return nil, fmt.Errorf("%s accepts only strings", name)
+		}
+		result = append(result, s)
+	}
+	return result, nil
+}
+
+func intArgs(name string, args []interface{}) ([]int64, error) {
+	result := []int64{}
+	for _, arg := range args {
+		n, ok := arg.(int64)
+		if !ok {
+			return nil, fmt.Errorf("%s accepts only numbers", name)
+		}
+		result = append(result, n)
+	}
+	return result, nil
+}
+
+func singleStringArg(name string, args []interface{}) (string, error) {
+	values, err := stringArgs(name, args)
+	if err != nil {
+		return "", err
+	}
+	if len(values) != 1 {
+		return "", fmt.Errorf("%s accepts exactly one argument", name)
+	}
+	return values[0], nil
+}
+
+// DefaultRegistry contains filters from this package & fields "text", "caption", "user", "username", "chat" & "chat_type".
+//
+// Filters without arguments: any, message, edited_message, channel_post, edited_channel_post, inline_query, callback_query,
+// private, group, supergroup, group_or_supergroup, channel, text, any_command, photo, voice, audio, animation, document,
+// sticker, video, video_note, contact, location, venue, new_chat_members, left_chat_member, mentions_bot, url, cashtag,
+// reply, reply_to_bot, forwarded, via_bot, automatic_forward, media_group, admin, creator.
+//
+// Filters with arguments: command("start", ...), regex("pattern") or regex(/pattern/), entity("type"), hashtag("tag"),
+// users(1, 2, ...), chats(1, 2, ...), usernames("foo", ...), mime("image/*", ...).
+var DefaultRegistry = NewRegistry().
+	RegisterFilter("any", Any()).
+	RegisterFilter("message", IsMessage()).
+	RegisterFilter("edited_message", IsEditedMessage()).
+	RegisterFilter("channel_post", IsChannelPost()).
+	RegisterFilter("edited_channel_post", IsEditedChannelPost()).
+	RegisterFilter("inline_query", IsInlineQuery()).
+	RegisterFilter("callback_query", IsCallbackQuery()).
+	RegisterFilter("private", IsPrivate()).
+	RegisterFilter("group", IsGroup()).
+	RegisterFilter("supergroup", IsSuperGroup()).
+	RegisterFilter("group_or_supergroup", IsGroupOrSuperGroup()).
+	RegisterFilter("channel", IsChannel()).
+	RegisterFilter("text", HasText()).
+	RegisterFilter("any_command", IsAnyCommandMessage()).
+	RegisterFilter("photo", HasPhoto()).
+	RegisterFilter("voice", HasVoice()).
+	RegisterFilter("audio", HasAudio()).
+	RegisterFilter("animation", HasAnimation()).
+	RegisterFilter("document", HasDocument()).
+	RegisterFilter("sticker", HasSticker()).
+	RegisterFilter("video", HasVideo()).
+	RegisterFilter("video_note", HasVideoNote()).
+	RegisterFilter("contact", HasContact()).
+	RegisterFilter("location", HasLocation()).
+	RegisterFilter("venue", HasVenue()).
+	RegisterFilter("new_chat_members", IsNewChatMembers()).
+	RegisterFilter("left_chat_member", IsLeftChatMember()).
+	RegisterFilter("mentions_bot", MentionsBot()).
+	RegisterFilter("url", HasURL()).
+	RegisterFilter("cashtag", HasCashtag()).
+	RegisterFilter("reply", IsReplyTo()).
+	RegisterFilter("reply_to_bot", IsReplyToBot()).
+	RegisterFilter("forwarded", IsForwarded()).
+	RegisterFilter("via_bot", IsViaBot()).
+	RegisterFilter("automatic_forward", IsAutomaticForward()).
+	RegisterFilter("media_group", HasMediaGroup()).
+	RegisterFilter("admin", IsChatAdmin()).
+	RegisterFilter("creator", IsChatCreator()).
+	Register("command", func(args []interface{}) (FilterFunc, error) {
+		commands, err := stringArgs("command", args)
+		if err != nil {
+			return nil, err
+		}
+		if len(commands) == 0 {
+			return nil, fmt.Errorf("command requires at least one argument")
+		}
+		filters := []FilterFunc{}
+		for _, command := range commands {
+			filters = append(filters, IsCommandMessage(command))
+		}
+		return Or(filters...), nil
+	}).
+	Register("regex", func(args []interface{}) (FilterFunc, error) {
+		if len(args) == 1 {
+			if exp, ok := args[0].(*regexp.Regexp); ok {
+				return HasRegex(exp.String()), nil
+			}
+		}
+		pattern, err := singleStringArg("regex", args)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := regexp.Compile(pattern); err != nil {
+			return nil, err
+		}
+		return HasRegex(pattern), nil
+	}).
+	Register("entity", func(args []interface{}) (FilterFunc, error) {
+		entityType, err := singleStringArg("entity", args)
+		if err != nil {
+			return nil, err
+		}
+		return HasEntity(entityType), nil
+	}).
+	Register("hashtag", func(args []interface{}) (FilterFunc, error) {
+		if len(args) == 0 {
+			return HasHashtag(""), nil
+		}
+		tag, err := singleStringArg("hashtag", args)
+		if err != nil {
+			return nil, err
+		}
+		return HasHashtag(tag), nil
+	}).
+	Register("users", func(args []interface{}) (FilterFunc, error) {
+		ids, err := intArgs("users", args)
+		if err != nil {
+			return nil, err
+		}
+		return FromUsers(ids...), nil
+	}).
+	Register("chats", func(args []interface{}) (FilterFunc, error) {
+		ids, err := intArgs("chats", args)
+		if err != nil {
+			return nil, err
+		}
+		return InChats(ids...), nil
+	}).
+	Register("usernames", func(args []interface{}) (FilterFunc, error) {
+		usernames, err := stringArgs("usernames", args)
+		if err != nil {
+			return nil, err
+		}
+		return FromUsernames(usernames...), nil
+	}).
+	Register("mime", func(args []interface{}) (FilterFunc, error) {
+		patterns, err := stringArgs("mime", args)
+		if err != nil {
+			return nil, err
+		}
+		return DocumentMIME(patterns...), nil
+	}).
+	RegisterField("text", func(u *Update) (interface{}, bool) {
+		message := u.EffectiveMessage()
+		return u.EffectiveText(false), message != nil
+	}).
+	RegisterField("caption", func(u *Update) (interface{}, bool) {
+		message := u.EffectiveMessage()
+		if message == nil {
+			return nil, false
+		}
+		return message.Caption, true
+	}).
+	RegisterField("user", func(u *Update) (interface{}, bool) {
+		if user := u.EffectiveUser(); user != nil {
+			return user.ID, true
+		}
+		return nil, false
+	}).
+	RegisterField("username", func(u *Update) (interface{}, bool) {
+		if user := u.EffectiveUser(); user != nil {
+			return user.UserName, true
+		}
+		return nil, false
+	}).
+	RegisterField("chat", func(u *Update) (interface{}, bool) {
+		if chat := u.EffectiveChat(); chat != nil {
+			return chat.ID, true
+		}
+		return nil, false
+	}).
+	RegisterField("chat_type", func(u *Update) (interface{}, bool) {
+		if chat := u.EffectiveChat(); chat != nil {
+			return chat.Type, true
+		}
+		return nil, false
+	})
+
+// ParseFilter parses filter expression using DefaultRegistry.
+//
+// Expressions consist of filters (`private`, `command("start")`), comparisons of fields with values
+// (`text ~ /^hi/i`, `user in [123, 456]`, `chat_type == "group"`, `username != "foo"`),
+// logical operators (`&&`, `||`, `!`) and parentheses. For example:
+//
+//	private && (command("start") || text ~ /^hi/i) && !user in [123, 456]
+//
+// Values can be strings ("foo", with Go-style escapes), integers (-100123), regular expressions (/pattern/flags, flags are i, m & s)
+// and lists of values ([1, 2, 3]).
+//
+// Every filter & comparison is wrapped with Named, so its source text is displayed in trace when Mux debug mode is enabled.
+func ParseFilter(expr string) (FilterFunc, error) {
+	return DefaultRegistry.Parse(expr)
+}
+
+// MustParseFilter is like ParseFilter but panics if expression cannot be parsed.
+func MustParseFilter(expr string) FilterFunc {
+	filter, err := ParseFilter(expr)
+	if err != nil {
+		panic(err)
+	}
+	return filter
+}
+
+// Parse parses filter expression using filters & fields from this registry. See ParseFilter for syntax.
+func (r *Registry) Parse(expr string) (FilterFunc, error) {
+	tokens, err := tokenize(expr)
+	if err != nil {
+		return nil, err
+	}
+	p := &exprParser{registry: r, expr: expr, tokens: tokens}
+	filter, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if token := p.peek(); token.kind != tokenEOF {
+		return nil, p.errorf(token, "unexpected %s", token)
+	}
+	return filter, nil
+}
+
+type tokenKind int
+
+const (
+	tokenEOF tokenKind = iota
+	tokenIdent
+	tokenString
+	tokenNumber
+	tokenRegex
+	tokenLParen
+	tokenRParen
+	tokenLBracket
+	tokenRBracket
+	tokenComma
+	tokenAnd
+	tokenOr
+	tokenNot
+	tokenEq
+	tokenNe
+	tokenMatch
+	tokenIn
+)
+
+type token struct {
+	kind  tokenKind
+	text  string
+	value interface{}
+	// start & end are byte offsets of token in expression
+	start int
+	end   int
+}
+
+func (t token) String() string {
+	if t.kind == tokenEOF {
+		return "end of expression"
+	}
+	return strconv.Quote(t.text)
+}
+
+func position(expr string, offset int) int {
+	return utf8.RuneCountInString(expr[:offset]) + 1
+}
+
+func isIdentRune(r rune, first bool) bool {
+	return r == '_' || unicode.IsLetter(r) || (!first && unicode.IsDigit(r))
+}
+
+func tokenize(expr string) ([]token, error) {
+	tokens := []token{}
+	operators := []struct {
+		text string
+		kind tokenKind
+	}{
+		{"&&", tokenAnd}, {"||", tokenOr}, {"==", tokenEq}, {"!=", tokenNe},
+		{"!", tokenNot}, {"~", tokenMatch}, {"(", tokenLParen}, {")", tokenRParen},
+		{"[", tokenLBracket}, {"]", tokenRBracket}, {",", tokenComma},
+	}
+	i := 0
+outer:
+	for i < len(expr) {
+		r, size := utf8.DecodeRuneInString(expr[i:])
+		if unicode.IsSpace(r) {
+			i += size
+			continue
+		}
+		for _, op := range operators {
+			if strings.HasPrefix(expr[i:], op.text) {
+				tokens = append(tokens, token{op.kind, op.text, nil, i, i + len(op.text)})
+				i += len(op.text)
+				continue outer
+			}
+		}
+		start := i
+		switch {
+		case isIdentRune(r, true):
+			for i < len(expr) {
+				r, size := utf8.DecodeRuneInString(expr[i:])
+				if !isIdentRune(r, false) {
+					break
+				}
+				i += size
+			}
+			kind := tokenIdent
+			if expr[start:i] == "in" {
+				kind = tokenIn
+			}
+			tokens = append(tokens, token{kind, expr[start:i], nil, start, i})
+		case r == '-' || (r >= '0' && r <= '9'):
+			i++
+			for i < len(expr) && expr[i] >= '0' && expr[i] <= '9' {
+				i++
+			}
+			n, err := strconv.ParseInt(expr[start:i], 10, 64)
+			if err != nil {
+				return nil, &ParseError{position(expr, start), fmt.Sprintf("invalid number %q", expr[start:i])}
+			}
+			tokens = append(tokens, token{tokenNumber, expr[start:i], n, start, i})
+		case r == '"':
+			i++
+			for i < len(expr) && expr[i] != '"' {
+				if expr[i] == '\\' {
+					i++
+				}
+				i++
+			}
+			if i >= len(expr) {
+				return nil, &ParseError{position(expr, start), "unterminated string"}
+			}
+			i++
+			s, err := strconv.Unquote(expr[start:i])
+			if err != nil {
+				return nil, &ParseError{position(expr, start), fmt.Sprintf("invalid string %s", expr[start:i])}
+			}
+			tokens = append(tokens, token{tokenString, expr[start:i], s, start, i})
+		case r == '/':
+			i++
+			pattern := strings.Builder{}
+			for i < len(expr) && expr[i] != '/' {
+				if expr[i] == '\\' && i+1 < len(expr) && expr[i+1] == '/' {
+					i++
+				}
+				pattern.WriteByte(expr[i])
+				i++
+			}
+			if i >= len(expr) {
+				return nil, &ParseError{position(expr, start), "unterminated regular expression"}
+			}
+			i++
+			flags := ""
+			for i < len(expr) && strings.IndexByte("ims", expr[i]) >= 0 {
+				flags += expr[i : i+1]
+				i++
+			}
+			source := pattern.String()
+			if flags != "" {
+				source = "(?" + flags + ")" + source
+			}
+			exp, err := regexp.Compile(source)
+			if err != nil {
+				return nil, &ParseError{position(expr, start), fmt.Sprintf("invalid regular expression: %s", err)}
+			}
+			tokens = append(tokens, token{tokenRegex, expr[start:i], exp, start, i})
+		default:
+			return nil, &ParseError{position(expr, start), fmt.Sprintf("unexpected character %q", r)}
+		}
+	}
+	tokens = append(tokens, token{tokenEOF, "", nil, len(expr), len(expr)})
+	return tokens, nil
+}
+
+type exprParser struct {
+	registry *Registry
+	expr     string
+	tokens   []token
+	pos      int
+}
+
+func (p *exprParser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *exprParser) next() token {
+	token := p.tokens[p.pos]
+	if token.kind != tokenEOF {
+		p.pos++
+	}
+	return token
+}
+
+func (p *exprParser) errorf(t token, format string, args ...interface{}) error {
+	return &ParseError{position(p.expr, t.start), fmt.Sprintf(format, args...)}
+}
+
+func (p *exprParser) expect(kind tokenKind, what string) (token, error) {
+	token := p.next()
+	if token.kind != kind {
+		return token, p.errorf(token, "expected %s, got %s", what, token)
+	}
+	return token, nil
+}
+
+func (p *exprParser) parseOr() (FilterFunc, error) {
+	filter, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	filters := []FilterFunc{filter}
+	for p.peek().kind == tokenOr {
+		p.next()
+		filter, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		filters = append(filters, filter)
+	}
+	if len(filters) == 1 {
+		return filters[0], nil
+	}
+	return Or(filters...), nil
+}
+
+func (p *exprParser) parseAnd() (FilterFunc, error) {
+	filter, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	filters := []FilterFunc{filter}
+	for p.peek().kind == tokenAnd {
+		p.next()
+		filter, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		filters = append(filters, filter)
+	}
+	if len(filters) == 1 {
+		return filters[0], nil
+	}
+	return And(filters...), nil
+}
+
+func (p *exprParser) parseUnary() (FilterFunc, error) {
+	if p.peek().kind == tokenNot {
+		p.next()
+		filter, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return Not(filter), nil
+	}
+	return p.parsePrimary()
+}
+
+func (p *exprParser) parsePrimary() (FilterFunc, error) {
+	token := p.next()
+	switch token.kind {
+	case tokenLParen:
+		filter, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if _, err := p.expect(tokenRParen, `")"`); err != nil {
+			return nil, err
+		}
+		return filter, nil
+	case tokenIdent:
+		switch p.peek().kind {
+		case tokenEq, tokenNe, tokenMatch, tokenIn:
+			return p.parseComparison(token)
+		}
+		return p.parseCall(token)
+	}
+	return nil, p.errorf(token, "expected filter, got %s", token)
+}
+
+func (p *exprParser) parseCall(name token) (FilterFunc, error) {
+	factory, ok := p.registry.filters[name.text]
+	if !ok {
+		return nil, p.errorf(name, "unknown filter %s", name)
+	}
+	args := []interface{}{}
+	end := name.end
+	if p.peek().kind == tokenLParen {
+		p.next()
+		if p.peek().kind != tokenRParen {
+			for {
+				value, err := p.parseValue()
+				if err != nil {
+					return nil, err
+				}
+				args = append(args, value)
+				if p.peek().kind != tokenComma {
+					break
+				}
+				p.next()
+			}
+		}
+		rparen, err := p.expect(tokenRParen, `")"`)
+		if err != nil {
+			return nil, err
+		}
+		end = rparen.end
+	}
+	filter, err := factory(args)
+	if err != nil {
+		return nil, p.errorf(name, "%s", err)
+	}
+	return Named(p.expr[name.start:end], filter), nil
+}
+
+func (p *exprParser) parseValue() (interface{}, error) {
+	token := p.next()
+	switch token.kind {
+	case tokenString, tokenNumber, tokenRegex:
+		return token.value, nil
+	case tokenLBracket:
+		values := []interface{}{}
+		if p.peek().kind == tokenRBracket {
+			p.next()
+			return values, nil
+		}
+		for {
+			value, err := p.parseValue()
+			if err != nil {
+				return nil, err
+			}
+			values = append(values, value)
+			separator := p.next()
+			if separator.kind == tokenRBracket {
+				return values, nil
+			}
+			if separator.kind != tokenComma {
+				return nil, p.errorf(separator, `expected "," or "]", got %s`, separator)
+			}
+		}
+	}
+	return nil, p.errorf(token, "expected value, got %s", token)
+}
+
+func (p *exprParser) parseComparison(name token) (FilterFunc, error) {
+	field, ok := p.registry.fields[name.text]
+	if !ok {
+		return nil, p.errorf(name, "unknown field %s", name)
+	}
+	op := p.next()
+	valueToken := p.peek()
+	value, err := p.parseValue()
+	if err != nil {
+		return nil, err
+	}
+	source := p.expr[name.start:p.tokens[p.pos-1].end]
+	var compare func(actual interface{}) bool
+	switch op.kind {
+	case tokenEq, tokenNe:
+		if _, isList := value.([]interface{}); isList {
+			return nil, p.errorf(valueToken, "cannot compare with list, use \"in\"")
+		}
+		if _, isRegex := value.(*regexp.Regexp); isRegex {
+			return nil, p.errorf(valueToken, "cannot compare with regular expression, use \"~\"")
+		}
+		negate := op.kind == tokenNe
+		compare = func(actual interface{}) bool {
+			return (actual == value) != negate
+		}
+	case tokenMatch:
+		exp, ok := value.(*regexp.Regexp)
+		if !ok {
+			return nil, p.errorf(valueToken, "expected regular expression, got %s", valueToken)
+		}
+		compare = func(actual interface{}) bool {
+			s, ok := actual.(string)
+			return ok && exp.MatchString(s)
+		}
+	case tokenIn:
+		values, ok := value.([]interface{})
+		if !ok {
+			return nil, p.errorf(valueToken, "expected list, got %s", valueToken)
+		}
+		compare = func(actual interface{}) bool {
+			for _, value := range values {
+				if actual == value {
+					return true
+				}
+			}
+			return false
+		}
+	}
+	return Named(source, func(u *Update) bool {
+		actual, ok := field(u)
+		return ok && compare(actual)
+	}), nil
+}
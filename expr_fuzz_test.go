@@ -0,0 +1,35 @@
+//go:build go1.18
+// +build go1.18
+
+package telemux_test
+
+import (
+	"errors"
+	"testing"
+	"unicode/utf8"
+
+	tm "github.com/and3rson/telemux/v2"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+func FuzzParseFilter(f *testing.F) {
+	f.Add(`private && (command("start") || text ~ /^hi/i) && !user in [123,456]`)
+	f.Add(`chat_type == "group" || username != "foo"`)
+	f.Add(`users(1, -2) && hashtag("go") && regex("\\d+")`)
+	f.Add(`text ~ /a\/b/ims`)
+	update := &tm.Update{Update: tgbotapi.Update{Message: &tgbotapi.Message{Text: "hi", Chat: &tgbotapi.Chat{}}}}
+	f.Fuzz(func(t *testing.T, expr string) {
+		filter, err := tm.ParseFilter(expr)
+		if err != nil {
+			var parseErr *tm.ParseError
+			if !errors.As(err, &parseErr) {
+				t.Fatalf("%q: expected ParseError, got %v", expr, err)
+			}
+			if parseErr.Pos < 1 || parseErr.Pos > utf8.RuneCountInString(expr)+1 {
+				t.Fatalf("%q: position %d is out of range", expr, parseErr.Pos)
+			}
+			return
+		}
+		filter(update)
+	})
+}
@@ -0,0 +1,144 @@
+package telemux_test
+
+import (
+	"errors"
+	"math/rand"
+	"strings"
+	"testing"
+
+	tm "github.com/and3rson/telemux/v2"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+func TestParseFilter(t *testing.T) {
+	NewTGUpdate := func(text string, chatType string, userID int64) *tm.Update {
+		u := tgbotapi.Update{}
+		u.Message = &tgbotapi.Message{
+			Text: text,
+			Chat: &tgbotapi.Chat{ID: 42, Type: chatType},
+			From: &tgbotapi.User{ID: userID, UserName: "foo"},
+		}
+		if strings.HasPrefix(text, "/") {
+			u.Message.Entities = []tgbotapi.MessageEntity{{Type: "bot_command", Offset: 0, Length: len(strings.Fields(text)[0])}}
+		}
+		return &tm.Update{Update: u}
+	}
+
+	filter, err := tm.ParseFilter(`private && (command("start") || text ~ /^hi/i) && !user in [123, 456]`)
+	assert(err == nil, t, err)
+	assert(filter(NewTGUpdate("/start", "private", 1)), t)
+	assert(filter(NewTGUpdate("Hi there", "private", 1)), t)
+	assert(!filter(NewTGUpdate("Hi there", "group", 1)), t)
+	assert(!filter(NewTGUpdate("Hi there", "private", 456)), t)
+	assert(!filter(NewTGUpdate("hello", "private", 1)), t)
+	assert(!filter(NewTGUpdate("/stop", "private", 1)), t)
+
+	filter = tm.MustParseFilter(`chat_type == "group" || username != "foo" && chat in [42]`)
+	assert(filter(NewTGUpdate("", "group", 1)), t)
+	assert(!filter(NewTGUpdate("", "private", 1)), t)
+
+	filter = tm.MustParseFilter(`!!text && regex("^a\\d") && users(1, 2)`)
+	assert(filter(NewTGUpdate("a1", "private", 1)), t)
+	assert(!filter(NewTGUpdate("ab", "private", 1)), t)
+	assert(!filter(NewTGUpdate("a1", "private", 3)), t)
+
+	filter = tm.MustParseFilter(`user in []`)
+	assert(!filter(NewTGUpdate("", "private", 1)), t)
+	assert(!filter(&tm.Update{}), t)
+}
+
+func TestParseFilterErrors(t *testing.T) {
+	cases := []struct {
+		expr string
+		pos  int
+		msg  string
+	}{
+		{``, 1, `expected filter, got end of expression`},
+		{`private &&`, 11, `expected filter, got end of expression`},
+		{`private group`, 9, `unexpected "group"`},
+		{`(private`, 9, `expected ")", got end of expression`},
+		{`unknown`, 1, `unknown filter "unknown"`},
+		{`private && foo == 1`, 12, `unknown field "foo"`},
+		{`command(1)`, 1, `command accepts only strings`},
+		{`private("x")`, 1, `private does not accept arguments`},
+		{`text ~ "hi"`, 8, `expected regular expression, got "\"hi\""`},
+		{`user in 1`, 9, `expected list, got "1"`},
+		{`user == [1]`, 9, `cannot compare with list, use "in"`},
+		{`user in [1 2]`, 12, `expected "," or "]", got "2"`},
+		{`text ~ /(/`, 8, "invalid regular expression: error parsing regexp: missing closing ): `(`"},
+		{`command("start)`, 9, `unterminated string`},
+		{`private & group`, 9, `unexpected character '&'`},
+		{`привіт`, 1, `unknown filter "привіт"`},
+		{`text == "ї" @`, 13, `unexpected character '@'`},
+	}
+	for _, c := range cases {
+		_, err := tm.ParseFilter(c.expr)
+		var parseErr *tm.ParseError
+		if !errors.As(err, &parseErr) {
+			t.Errorf("%q: expected ParseError, got %v", c.expr, err)
+			continue
+		}
+		assert(parseErr.Pos == c.pos, t, c.expr, parseErr.Pos, c.pos)
+		assert(parseErr.Msg == c.msg, t, c.expr, parseErr.Msg)
+	}
+}
+
+func TestRegistry(t *testing.T) {
+	registry := tm.NewRegistry().
+		RegisterFilter("yes", tm.Any()).
+		Register("len", func(args []interface{}) (tm.FilterFunc, error) {
+			n := args[0].(int64)
+			return func(u *tm.Update) bool {
+				return int64(len(u.EffectiveText(false))) == n
+			}, nil
+		}).
+		RegisterField("id", func(u *tm.Update) (interface{}, bool) {
+			return int64(u.UpdateID), true
+		})
+	filter, err := registry.Parse(`yes && len(3) && id == 7`)
+	assert(err == nil, t, err)
+	u := &tm.Update{Update: tgbotapi.Update{UpdateID: 7, Message: &tgbotapi.Message{Text: "foo"}}}
+	assert(filter(u), t)
+	u.UpdateID = 8
+	assert(!filter(u), t)
+
+	_, err = registry.Parse(`private`)
+	assert(err != nil && err.Error() == `position 1: unknown filter "private"`, t, err)
+}
+
+func TestParseFilterTrace(t *testing.T) {
+	var trace *tm.Trace
+	mux := tm.NewMux().
+		SetDebug(true).
+		SetTraceFunc(func(u *tm.Update) { trace = u.Trace }).
+		AddHandler(tm.NewHandler(tm.MustParseFilter(`private || text ~ /^hi/`), func(u *tm.Update) {}))
+	u := tgbotapi.Update{Message: &tgbotapi.Message{Text: "hello", Chat: &tgbotapi.Chat{Type: "group"}}}
+	assert(!mux.Dispatch(nil, u), t)
+	expected := `#0 *telemux.Handler: false
+  Or: false
+    private: false
+    text ~ /^hi/: false
+`
+	assert(trace.String() == expected, t, trace.String())
+}
+
+// TestParseFilterRandom feeds parser with random garbage composed of expression tokens: it must never panic.
+// See also FuzzParseFilter.
+func TestParseFilterRandom(t *testing.T) {
+	parts := []string{
+		"private", "text", "user", "command", "(", ")", "[", "]", ",", "&&", "||", "!", "==", "!=", "~", "in",
+		`"start"`, `"\`, "/^hi/i", "/", "123", "-", " ", "ї", "\xff",
+	}
+	random := rand.New(rand.NewSource(1))
+	update := &tm.Update{Update: tgbotapi.Update{Message: &tgbotapi.Message{Text: "hi", Chat: &tgbotapi.Chat{}}}}
+	for i := 0; i < 10000; i++ {
+		b := strings.Builder{}
+		for j := random.Intn(12); j >= 0; j-- {
+			b.WriteString(parts[random.Intn(len(parts))])
+		}
+		filter, err := tm.ParseFilter(b.String())
+		if err == nil {
+			filter(update)
+		}
+	}
+}
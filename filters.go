@@ -268,8 +268,9 @@ func IsLeftChatMember() FilterFunc {
 // And filters updates that pass ALL of the provided filters.
 func And(filters ...FilterFunc) FilterFunc {
 	return func(u *Update) bool {
+		u.nameTrace("And")
 		for _, filter := range filters {
-			if !filter(u) {
+			if !u.evalFilter(filter) {
 				return false
 			}
 		}
@@ -280,8 +281,9 @@ func And(filters ...FilterFunc) FilterFunc {
 // Or filters updates that pass ANY of the provided filters.
 func Or(filters ...FilterFunc) FilterFunc {
 	return func(u *Update) bool {
+		u.nameTrace("Or")
 		for _, filter := range filters {
-			if filter(u) {
+			if u.evalFilter(filter) {
 				return true
 			}
 		}
@@ -292,6 +294,7 @@ func Or(filters ...FilterFunc) FilterFunc {
 // Not filters updates that do not pass the provided filter.
 func Not(filter FilterFunc) FilterFunc {
 	return func(u *Update) bool {
-		return !filter(u)
+		u.nameTrace("Not")
+		return !u.evalFilter(filter)
 	}
 }
@@ -2,4 +2,7 @@ module github.com/and3rson/telemux/v2
 
 go 1.16
 
-require github.com/go-telegram-bot-api/telegram-bot-api/v5 v5.5.1
+require (
+	github.com/go-telegram-bot-api/telegram-bot-api/v5 v5.5.1
+	golang.org/x/text v0.3.7
+)
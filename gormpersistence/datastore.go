@@ -0,0 +1,36 @@
+package gormpersistence
+
+import (
+	tm "github.com/and3rson/telemux/v2"
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// GORMDataStore is an implementation of DataStore.
+// It stores user, chat & bot data in database via GORM.
+type GORMDataStore struct {
+	DB *gorm.DB
+}
+
+// AutoMigrate creates table for ScopedData model
+func (s *GORMDataStore) AutoMigrate() error {
+	return s.DB.AutoMigrate(&ScopedData{})
+}
+
+// GetData reads data from database
+func (s *GORMDataStore) GetData(key tm.DataKey) tm.Data {
+	var dataRecord ScopedData
+	s.DB.Where(key).Attrs(ScopedData{Data: datatypes.JSONMap{}}).FirstOrCreate(&dataRecord)
+	return dataRecord.Data
+}
+
+// SetData writes data to database
+func (s *GORMDataStore) SetData(key tm.DataKey, data tm.Data) {
+	s.DB.Clauses(clause.OnConflict{
+		UpdateAll: true,
+	}).Create(&ScopedData{
+		DataKey: key,
+		Data:    data,
+	})
+}
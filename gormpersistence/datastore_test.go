@@ -0,0 +1,32 @@
+package gormpersistence
+
+import (
+	tm "github.com/and3rson/telemux/v2"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"reflect"
+	"testing"
+)
+
+func TestDataStore(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open("file::memory:?cache=shared"), &gorm.Config{})
+	if err != nil {
+		t.Error(err)
+	}
+
+	s := GORMDataStore{db}
+	s.AutoMigrate()
+
+	userKey := tm.DataKey{Scope: tm.UserScope, ID: 13}
+	chatKey := tm.DataKey{Scope: tm.ChatScope, ID: 13}
+	if !reflect.DeepEqual(s.GetData(userKey), tm.Data{}) {
+		t.Error("Data should be an empty map")
+	}
+	s.SetData(userKey, tm.Data{"lang": "uk"})
+	if !reflect.DeepEqual(s.GetData(userKey), tm.Data{"lang": "uk"}) {
+		t.Error("Data should be [lang:uk]")
+	}
+	if !reflect.DeepEqual(s.GetData(chatKey), tm.Data{}) {
+		t.Error("Chat data should be an empty map")
+	}
+}
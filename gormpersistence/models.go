@@ -16,3 +16,15 @@ type ConversationData struct {
 	tm.PersistenceKey
 	Data datatypes.JSONMap `gorm:"not null"`
 }
+
+// ScopedData is a model that contains user, chat & bot data.
+type ScopedData struct {
+	tm.DataKey
+	Data datatypes.JSONMap `gorm:"not null"`
+}
+
+// RateLimitBucket is a model that contains token buckets of rate limiters.
+type RateLimitBucket struct {
+	Key string `gorm:"primaryKey"`
+	tm.TokenBucket
+}
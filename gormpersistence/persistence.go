@@ -51,3 +51,31 @@ func (p *GORMPersistence) SetData(pk tm.PersistenceKey, data tm.Data) {
 		Data:           data,
 	})
 }
+
+// Keys returns all conversation keys stored in database
+func (p *GORMPersistence) Keys() ([]tm.PersistenceKey, error) {
+	var stateRecords []ConversationState
+	var dataRecords []ConversationData
+	if err := p.DB.Select("conversation_id", "user_id", "chat_id").Find(&stateRecords).Error; err != nil {
+		return nil, err
+	}
+	if err := p.DB.Select("conversation_id", "user_id", "chat_id").Find(&dataRecords).Error; err != nil {
+		return nil, err
+	}
+	seen := make(map[tm.PersistenceKey]bool)
+	keys := []tm.PersistenceKey{}
+	for _, record := range stateRecords {
+		if !seen[record.PersistenceKey] {
+			seen[record.PersistenceKey] = true
+			keys = append(keys, record.PersistenceKey)
+		}
+	}
+	for _, record := range dataRecords {
+		if !seen[record.PersistenceKey] {
+			seen[record.PersistenceKey] = true
+			keys = append(keys, record.PersistenceKey)
+		}
+	}
+	tm.SortKeys(keys)
+	return keys, nil
+}
@@ -32,4 +32,21 @@ func TestPersistence(t *testing.T) {
 	if !reflect.DeepEqual(p.GetData(pk), tm.Data{"foo": "bar"}) {
 		t.Error("State should be [foo:bar]")
 	}
+
+	pk2 := tm.PersistenceKey{ConversationID: "b", UserID: 1, ChatID: 2}
+	p.SetData(pk2, tm.Data{})
+	if keys, err := p.Keys(); err != nil || !reflect.DeepEqual(keys, []tm.PersistenceKey{pk, pk2}) {
+		t.Error("Keys should be [a:13:37 b:1:2]")
+	}
+
+	// Database errors are not swallowed
+	if err := db.Migrator().DropTable(&ConversationData{}); err != nil {
+		t.Error(err)
+	}
+	if _, err := p.Keys(); err == nil {
+		t.Error("Keys should fail without table")
+	}
+	if err := tm.Migrate(&p, tm.NewLocalPersistence()); err == nil {
+		t.Error("Migrate should fail without table")
+	}
 }
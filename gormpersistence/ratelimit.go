@@ -0,0 +1,38 @@
+package gormpersistence
+
+import (
+	tm "github.com/and3rson/telemux/v2"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// GORMRateLimitStore is an implementation of RateLimitStore.
+// It stores token buckets of rate limiters in database via GORM, so limits survive restarts.
+type GORMRateLimitStore struct {
+	DB *gorm.DB
+}
+
+// AutoMigrate creates table for RateLimitBucket model
+func (s *GORMRateLimitStore) AutoMigrate() error {
+	return s.DB.AutoMigrate(&RateLimitBucket{})
+}
+
+// GetBucket reads bucket from database
+func (s *GORMRateLimitStore) GetBucket(key string) (tm.TokenBucket, bool) {
+	var bucketRecord RateLimitBucket
+	result := s.DB.Where(RateLimitBucket{Key: key}).Limit(1).Find(&bucketRecord)
+	if result.Error != nil || result.RowsAffected == 0 {
+		return tm.TokenBucket{}, false
+	}
+	return bucketRecord.TokenBucket, true
+}
+
+// SetBucket writes bucket to database
+func (s *GORMRateLimitStore) SetBucket(key string, bucket tm.TokenBucket) {
+	s.DB.Clauses(clause.OnConflict{
+		UpdateAll: true,
+	}).Create(&RateLimitBucket{
+		Key:         key,
+		TokenBucket: bucket,
+	})
+}
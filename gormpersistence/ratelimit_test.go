@@ -0,0 +1,33 @@
+package gormpersistence
+
+import (
+	tm "github.com/and3rson/telemux/v2"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"testing"
+	"time"
+)
+
+func TestRateLimitStore(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open("file::memory:?cache=shared"), &gorm.Config{})
+	if err != nil {
+		t.Error(err)
+	}
+
+	s := GORMRateLimitStore{db}
+	s.AutoMigrate()
+
+	if _, ok := s.GetBucket("search:user:1"); ok {
+		t.Error("Bucket should not exist")
+	}
+	updated := time.Date(2021, 5, 1, 12, 0, 0, 0, time.UTC)
+	s.SetBucket("search:user:1", tm.TokenBucket{Tokens: 1.5, Updated: updated})
+	s.SetBucket("search:user:1", tm.TokenBucket{Tokens: 0.5, Updated: updated, Notified: true})
+	bucket, ok := s.GetBucket("search:user:1")
+	if !ok || bucket.Tokens != 0.5 || !bucket.Notified || !bucket.Updated.Equal(updated) {
+		t.Errorf("Bucket should be updated, got %v", bucket)
+	}
+	if _, ok := s.GetBucket("search:user:2"); ok {
+		t.Error("Bucket of another user should not exist")
+	}
+}
@@ -12,11 +12,20 @@ type HandleFunc func(u *Update)
 type Handler struct {
 	Filter  FilterFunc
 	Handles []HandleFunc
+	// Command describes command handled by this handler, if any (see Describe).
+	Command *CommandInfo
+
+	// conversationID, persistence, states & defaults are set for conversation handlers
+	conversationID string
+	persistence    ConversationPersistence
+	states         StateMap
+	defaults       []*Handler
 }
 
 // Process runs handler with provided Update.
 func (h *Handler) Process(u *Update) bool {
-	if h.Filter(u) {
+	if u.evalFilter(h.Filter) {
+		defer u.pauseTrace()()
 		for i := 0; i < len(h.Handles) && !u.Consumed; i++ {
 			h.Handles[i](u)
 		}
@@ -30,7 +39,7 @@ func NewHandler(filter FilterFunc, handles ...HandleFunc) *Handler {
 	if filter == nil {
 		filter = Any()
 	}
-	return &Handler{filter, handles}
+	return &Handler{Filter: filter, Handles: handles}
 }
 
 // NewMessageHandler creates a handler for updates that contain message.
@@ -43,6 +52,8 @@ func NewMessageHandler(filter FilterFunc, handles ...HandleFunc) *Handler {
 }
 
 // NewRegexHandler creates a handler for updates that contain message which matches the pattern as regexp.
+// It populates u.Context["matches"] with a slice of matches and u.Context["groups"] with a map of named capture groups.
+// See also NewTextRegexHandler which also handles edited messages, channel posts & captions.
 func NewRegexHandler(pattern string, filter FilterFunc, handles ...HandleFunc) *Handler {
 	exp := regexp.MustCompile(pattern)
 	newFilter := And(IsMessage(), func(u *Update) bool {
@@ -53,23 +64,67 @@ func NewRegexHandler(pattern string, filter FilterFunc, handles ...HandleFunc) *
 	}
 	handles = append([]HandleFunc{
 		func(u *Update) {
+			matches := exp.FindStringSubmatch(u.Message.Text)
 			u.Context["exp"] = exp
-			u.Context["matches"] = exp.FindStringSubmatch(u.Message.Text)
+			u.Context["matches"] = matches
+			u.Context["groups"] = namedGroups(exp, matches)
+		},
+	}, handles...)
+	return NewHandler(newFilter, handles...)
+}
+
+// namedGroups returns a map of named capture groups of a regular expression.
+func namedGroups(exp *regexp.Regexp, matches []string) map[string]string {
+	groups := make(map[string]string)
+	if matches == nil {
+		return groups
+	}
+	for i, name := range exp.SubexpNames() {
+		if name != "" {
+			groups[name] = matches[i]
+		}
+	}
+	return groups
+}
+
+// NewTextRegexHandler creates a handler for updates that contain any kind of message (new or edited message, channel post etc)
+// which text or caption matches the pattern as regexp. Callback queries are not matched.
+//
+// It populates u.Context["exp"] with compiled regexp, u.Context["matches"] with a slice of matches
+// and u.Context["groups"] with a map of named capture groups.
+//
+// For example, pattern `^/get_(?P<id>\d+)$` will set u.Context["groups"] to map[string]string{"id": "42"} for "/get_42".
+func NewTextRegexHandler(pattern string, filter FilterFunc, handles ...HandleFunc) *Handler {
+	exp := regexp.MustCompile(pattern)
+	newFilter := And(Not(IsCallbackQuery()), func(u *Update) bool {
+		return u.EffectiveMessage() != nil && exp.MatchString(u.EffectiveText(true))
+	})
+	if filter != nil {
+		newFilter = And(newFilter, filter)
+	}
+	handles = append([]HandleFunc{
+		func(u *Update) {
+			matches := exp.FindStringSubmatch(u.EffectiveText(true))
+			u.Context["exp"] = exp
+			u.Context["matches"] = matches
+			u.Context["groups"] = namedGroups(exp, matches)
 		},
 	}, handles...)
 	return NewHandler(newFilter, handles...)
 }
 
 // NewCommandHandler is an extension for NewMessageHandler that creates a handler for updates that contain message with command.
-// It also populates u.Context["args"] with a slice of strings.
+// It also populates u.Context["args"] with a slice of strings, split by whitespace with support for quotes (see SplitArgs).
 //
-// For example, when invoked as `/somecmd foo bar 1337`, u.Context["args"] will be set to []string{"foo", "bar", "1337"}
+// For example, when invoked as `/somecmd foo "bar baz" 1337`, u.Context["args"] will be set to []string{"foo", "bar baz", "1337"}
 //
 // command can be a string (like "start" or "somecmd") or a space-delimited list of commands to accept (like "start somecmd othercmd")
+//
+// Use Args filter to parse & validate typed arguments.
 func NewCommandHandler(command string, filter FilterFunc, handles ...HandleFunc) *Handler {
 	handles = append([]HandleFunc{
 		func(u *Update) {
-			u.Context["args"] = strings.Split(u.Message.Text, " ")[1:]
+			u.Context["args"] = SplitArgs(CommandArgs(u.Message.Text))
 		},
 	}, handles...)
 	commandFilters := []FilterFunc{}
@@ -80,10 +135,12 @@ func NewCommandHandler(command string, filter FilterFunc, handles ...HandleFunc)
 	if filter != nil {
 		newFilter = And(newFilter, filter)
 	}
-	return NewMessageHandler(
+	handler := NewMessageHandler(
 		newFilter,
 		handles...,
 	)
+	handler.Command = &CommandInfo{Names: strings.Split(command, " ")}
+	return handler
 }
 
 // NewInlineQueryHandler creates a handler for updates that contain inline query which matches the pattern as regexp.
@@ -174,7 +231,7 @@ func NewConversationHandler(
 	// TODO: Filters are called twice
 	var handler *Handler
 	handler = &Handler{
-		func(u *Update) bool {
+		Filter: func(u *Update) bool {
 			user, chat := u.EffectiveUser(), u.EffectiveChat()
 			if user == nil || chat == nil {
 				return false
@@ -190,14 +247,14 @@ func NewConversationHandler(
 				PK:          pk,
 			}
 			defer func() { u.PersistenceContext = nil }()
-			for _, handler := range candidates {
-				if handler.Filter(u) {
+			for i, handler := range candidates {
+				if u.evalLabeledFilter(handler.Filter, "%s[%q] #%d", conversationID, state, i) {
 					return true
 				}
 			}
 			return false
 		},
-		[]HandleFunc{func(u *Update) {
+		Handles: []HandleFunc{func(u *Update) {
 			user, chat := u.EffectiveUser(), u.EffectiveChat()
 			pk := PersistenceKey{conversationID, user.ID, chat.ID}
 			state := persistence.GetState(pk)
@@ -231,6 +288,10 @@ func NewConversationHandler(
 				}
 			}
 		}},
+		conversationID: conversationID,
+		persistence:    persistence,
+		states:         states,
+		defaults:       defaults,
 	}
 	return handler
 }
@@ -54,7 +54,7 @@ func TestHandlerConsume(t *testing.T) {
 		func(u *tm.Update) { b = true; u.Consume() },
 		func(u *tm.Update) { c = true },
 	)
-	u := &tm.Update{tgbotapi.Update{}, nil, false, nil, nil}
+	u := &tm.Update{Update: tgbotapi.Update{}}
 	if !h.Process(u) {
 		t.Error("Handler should return true")
 	}
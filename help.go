@@ -0,0 +1,164 @@
+package telemux
+
+import (
+	"fmt"
+	"html"
+	"strings"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// HelpFormat defines markup of help message.
+type HelpFormat string
+
+// Help formats supported by Mux.Help & NewHelpHandler. Values are Telegram parse modes.
+const (
+	HelpMarkdown HelpFormat = tgbotapi.ModeMarkdownV2
+	HelpHTML     HelpFormat = tgbotapi.ModeHTML
+)
+
+var markdownV2Escaper = strings.NewReplacer(
+	"_", "\\_", "*", "\\*", "[", "\\[", "]", "\\]", "(", "\\(", ")", "\\)", "~", "\\~", "`", "\\`", ">", "\\>",
+	"#", "\\#", "+", "\\+", "-", "\\-", "=", "\\=", "|", "\\|", "{", "\\{", "}", "\\}", ".", "\\.", "!", "\\!",
+	"\\", "\\\\",
+)
+
+func (f HelpFormat) escape(s string) string {
+	if f == HelpHTML {
+		return html.EscapeString(s)
+	}
+	return markdownV2Escaper.Replace(s)
+}
+
+func (f HelpFormat) bold(s string) string {
+	if f == HelpHTML {
+		return "<b>" + html.EscapeString(s) + "</b>"
+	}
+	return "*" + markdownV2Escaper.Replace(s) + "*"
+}
+
+// scopeVisible checks if command of a scope should be listed in a chat.
+func scopeVisible(scopes []CommandScope, chat *tgbotapi.Chat) bool {
+	if len(scopes) == 0 {
+		return true
+	}
+	for _, scope := range scopes {
+		switch scope {
+		case ScopeDefault:
+			return true
+		case ScopePrivate:
+			if chat == nil || chat.IsPrivate() {
+				return true
+			}
+		case ScopeGroups, ScopeAdmins:
+			if chat != nil && (chat.IsGroup() || chat.IsSuperGroup()) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// probe checks if handler would accept command sent by the author of the update.
+// Handlers of conversation states are checked only if the user is currently in that state.
+// Filters are called with a copy of the update, so they do not affect the original one.
+func probe(u *Update, path []*Mux, scopes []stateScope, h *Handler, command string) bool {
+	copied := *u
+	copied.Context = make(Map)
+	copied.Consumed = false
+	copied.Trace = nil
+	copied.PersistenceContext = nil
+	copied.probing = true
+	if u.Message != nil {
+		message := *u.Message
+		message.Text = "/" + command
+		message.Entities = []tgbotapi.MessageEntity{{Type: "bot_command", Length: len(message.Text)}}
+		copied.Message = &message
+	}
+	for _, m := range path {
+		if m.GlobalFilter != nil && !m.GlobalFilter(&copied) {
+			return false
+		}
+	}
+	for _, scope := range scopes {
+		user, chat := u.EffectiveUser(), u.EffectiveChat()
+		if user == nil || chat == nil {
+			return false
+		}
+		pk := PersistenceKey{scope.conversation.conversationID, user.ID, chat.ID}
+		state := scope.conversation.persistence.GetState(pk)
+		if scope.isDefault && state == "" || !scope.isDefault && state != scope.state {
+			return false
+		}
+		copied.PersistenceContext = &PersistenceContext{Persistence: scope.conversation.persistence, PK: pk}
+	}
+	return h.Filter(&copied)
+}
+
+// Help renders a list of described commands (see Handler.Describe) which are available to the author of the update.
+//
+// Command is listed only if filters of its handler & global filters of all multiplexers on the way to it
+// accept "/command" sent by the same user in the same chat, so e. g. admin-only commands are hidden from non-admins.
+// Commands of conversation states are listed only if the user is currently in that state.
+// Scopes are also respected: ScopePrivate commands are listed in private chats only,
+// ScopeGroups & ScopeAdmins commands are listed in groups only.
+//
+// Commands are grouped by the nearest named multiplexer (see Mux.SetName). Descriptions are localized according to
+// user's language. Returns empty string if no commands are available.
+func (m *Mux) Help(u *Update, format HelpFormat) string {
+	language := ""
+	if user := u.EffectiveUser(); user != nil {
+		language = user.LanguageCode
+	}
+	chat := u.EffectiveChat()
+	groups := []string{}
+	lines := make(map[string][]string)
+	seen := make(map[string]bool)
+	m.walkHandlers(nil, func(path []*Mux, scopes []stateScope, h *Handler) {
+		if h.Command == nil || h.Command.Description == "" || !scopeVisible(h.Command.Scopes, chat) {
+			return
+		}
+		group := ""
+		for _, mux := range path {
+			if mux.Name != "" {
+				group = mux.Name
+			}
+		}
+		for _, name := range h.Command.Names {
+			if seen[name] || !probe(u, path, scopes, h, name) {
+				continue
+			}
+			seen[name] = true
+			if _, ok := lines[group]; !ok {
+				groups = append(groups, group)
+			}
+			description := Command{name, h.Command.Description, h.Command.Descriptions, h.Command.Scopes}.LocalizedDescription(language)
+			lines[group] = append(lines[group], format.escape(fmt.Sprintf("/%s - %s", name, description)))
+		}
+	})
+	sections := []string{}
+	for _, group := range groups {
+		section := strings.Join(lines[group], "\n")
+		if group != "" {
+			section = format.bold(group) + "\n" + section
+		}
+		sections = append(sections, section)
+	}
+	return strings.Join(sections, "\n\n")
+}
+
+// NewHelpHandler creates a handler for "/help" command which replies with a list of commands available to the user
+// (see Mux.Help). mux is usually the root multiplexer of the bot.
+//
+// Handler is described as "Show available commands", use Describe to change this.
+func NewHelpHandler(mux *Mux, format HelpFormat, filter FilterFunc) *Handler {
+	return NewCommandHandler("help", filter, func(u *Update) {
+		text := mux.Help(u, format)
+		if text == "" {
+			text = format.escape("No commands available.")
+		}
+		reply := tgbotapi.NewMessage(u.Message.Chat.ID, text)
+		reply.ParseMode = string(format)
+		u.Bot.Send(reply)
+	}).Describe("Show available commands")
+}
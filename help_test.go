@@ -0,0 +1,82 @@
+package telemux_test
+
+import (
+	"testing"
+
+	tm "github.com/and3rson/telemux/v2"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+func TestMuxHelp(t *testing.T) {
+	isAdmin := func(u *tm.Update) bool { return u.EffectiveUser().ID == 1 }
+	mux := tm.NewMux().
+		AddHandler(tm.NewCommandHandler("start", nil).Describe("Start the bot").DescribeIn("uk", "Почати")).
+		AddHandler(tm.NewCommandHandler("hidden", nil)).
+		AddMux(tm.NewMux().
+			SetName("Moderation").
+			SetGlobalFilter(tm.IsGroupOrSuperGroup()).
+			AddHandler(tm.NewCommandHandler("ban", tm.And(isAdmin, tm.Args(tm.UserRef("target")))).Describe("Ban user (admins only)")).
+			AddHandler(tm.NewCommandHandler("report", nil).Describe("Report message")),
+		).
+		AddMux(tm.NewMux().
+			SetName("Settings").
+			AddHandler(tm.NewCommandHandler("settings", nil).Describe("Open settings").InScope(tm.ScopePrivate)),
+		)
+	mux.AddHandler(tm.NewHelpHandler(mux, tm.HelpMarkdown, nil))
+
+	NewUpdate := func(userID int64, chatType string, language string) *tm.Update {
+		u := &tm.Update{}
+		u.Message = &tgbotapi.Message{
+			Text: "/help",
+			From: &tgbotapi.User{ID: userID, LanguageCode: language},
+			Chat: &tgbotapi.Chat{Type: chatType},
+		}
+		return u
+	}
+
+	help := mux.Help(NewUpdate(1, "group", ""), tm.HelpMarkdown)
+	expected := "/start \\- Start the bot\n/help \\- Show available commands\n\n" +
+		"*Moderation*\n/ban \\- Ban user \\(admins only\\)\n/report \\- Report message"
+	assert(help == expected, t, help)
+
+	help = mux.Help(NewUpdate(2, "supergroup", "uk"), tm.HelpHTML)
+	expected = "/start - Почати\n/help - Show available commands\n\n<b>Moderation</b>\n/report - Report message"
+	assert(help == expected, t, help)
+
+	help = mux.Help(NewUpdate(1, "private", ""), tm.HelpHTML)
+	expected = "/start - Start the bot\n/help - Show available commands\n\n<b>Settings</b>\n/settings - Open settings"
+	assert(help == expected, t, help)
+
+	u := NewUpdate(1, "private", "")
+	u.Context = tm.Map{"foo": "bar"}
+	mux.Help(u, tm.HelpHTML)
+	assert(u.Message.Text == "/help" && len(u.Context) == 1 && !u.Consumed, t, u)
+}
+
+func TestMuxHelpConversation(t *testing.T) {
+	persistence := tm.NewLocalPersistence()
+	mux := tm.NewMux().AddHandler(tm.NewConversationHandler(
+		"editor",
+		persistence,
+		tm.StateMap{
+			"": {
+				tm.NewCommandHandler("edit", nil).Describe("Edit note"),
+				tm.NewHandler(tm.HasText(), nil),
+			},
+			"edit": {
+				tm.NewHandler(tm.HasText(), nil),
+				tm.NewCommandHandler("save", nil).Describe("Save note"),
+			},
+		},
+		[]*tm.Handler{tm.NewCommandHandler("cancel", nil).Describe("Cancel editing")},
+	))
+
+	u := &tm.Update{}
+	u.Message = &tgbotapi.Message{Text: "/help", From: &tgbotapi.User{ID: 1}, Chat: &tgbotapi.Chat{ID: 1, Type: "private"}}
+	help := mux.Help(u, tm.HelpHTML)
+	assert(help == "/edit - Edit note", t, help)
+
+	persistence.SetState(tm.PersistenceKey{"editor", 1, 1}, "edit")
+	help = mux.Help(u, tm.HelpHTML)
+	assert(help == "/save - Save note\n/cancel - Cancel editing", t, help)
+}
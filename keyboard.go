@@ -0,0 +1,257 @@
+package telemux
+
+import (
+	"fmt"
+	"log"
+	"unicode/utf8"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// Keyboard is an inline keyboard which handles its own callback queries, e. g. Menu or Pager.
+// Keyboards are registered with Mux.AddKeyboard.
+type Keyboard interface {
+	Handler() *Handler
+}
+
+// AddKeyboard adds handlers of one or more keyboards to multiplexer.
+// This function returns the receiver for convenient chaining.
+func (m *Mux) AddKeyboard(keyboards ...Keyboard) *Mux {
+	for _, keyboard := range keyboards {
+		m.AddHandler(keyboard.Handler())
+	}
+	return m
+}
+
+// Layout defines how buttons are wrapped into rows.
+type Layout struct {
+	// Columns is a maximum number of buttons in a row. Zero means no limit.
+	Columns int
+	// MaxRowWidth is a maximum total length of button texts in a row (in characters). Zero means no limit.
+	// A button which is wider than MaxRowWidth occupies a row of its own.
+	MaxRowWidth int
+}
+
+// DefaultLayout puts up to 3 buttons into a row, keeping rows short enough to fit on phone screens.
+var DefaultLayout = Layout{Columns: 3, MaxRowWidth: 30}
+
+// Wrap splits buttons into rows.
+func (l Layout) Wrap(buttons []tgbotapi.InlineKeyboardButton) [][]tgbotapi.InlineKeyboardButton {
+	rows := [][]tgbotapi.InlineKeyboardButton{}
+	row := []tgbotapi.InlineKeyboardButton{}
+	width := 0
+	for _, button := range buttons {
+		buttonWidth := utf8.RuneCountInString(button.Text)
+		full := l.Columns > 0 && len(row) >= l.Columns
+		tooWide := l.MaxRowWidth > 0 && width+buttonWidth > l.MaxRowWidth
+		if len(row) > 0 && (full || tooWide) {
+			rows = append(rows, row)
+			row, width = []tgbotapi.InlineKeyboardButton{}, 0
+		}
+		row = append(row, button)
+		width += buttonWidth
+	}
+	if len(row) > 0 {
+		rows = append(rows, row)
+	}
+	return rows
+}
+
+type menuItem struct {
+	key    string
+	text   string
+	handle HandleFunc
+}
+
+// Menu is an inline keyboard with a fixed list of items, each of them having its own handle function.
+//
+//	menu := tm.NewMenu("settings").
+//	    AddItem("Language", func(u *tm.Update) { /* ... */ }).
+//	    AddItem("Notifications", func(u *tm.Update) { /* ... */ })
+//	mux.AddKeyboard(menu)
+//	// ...
+//	msg := tgbotapi.NewMessage(chatID, "Settings")
+//	msg.ReplyMarkup = menu.Markup()
+type Menu struct {
+	Route  *CallbackRoute
+	Layout Layout
+	items  []menuItem
+	byKey  map[string]int
+}
+
+// NewMenu creates a menu. name must be unique among callback routes (see NewCallbackRoute).
+// Buttons refer to items by their keys. Keys which do not fit into MaxCallbackData are kept in LocalCallbackStore,
+// use Route.SetStore to change this.
+func NewMenu(name string) *Menu {
+	return &Menu{
+		Route:  NewCallbackRoute(name, "").SetStore(NewLocalCallbackStore()),
+		Layout: DefaultLayout,
+		byKey:  make(map[string]int),
+	}
+}
+
+// AddItem adds a button which calls handle when pressed. Text of the button is used as its key (see AddKeyedItem).
+// This function returns the receiver for convenient chaining.
+func (m *Menu) AddItem(text string, handle HandleFunc) *Menu {
+	return m.AddKeyedItem(text, text, handle)
+}
+
+// AddKeyedItem adds a button which calls handle when pressed. Buttons of already sent menus refer to items by key,
+// so they keep working after items are reordered or renamed. Panics if key is already used.
+// This function returns the receiver for convenient chaining.
+func (m *Menu) AddKeyedItem(key string, text string, handle HandleFunc) *Menu {
+	if _, ok := m.byKey[key]; ok {
+		panic(fmt.Sprintf("duplicate menu item key %q", key))
+	}
+	m.byKey[key] = len(m.items)
+	m.items = append(m.items, menuItem{key, text, handle})
+	return m
+}
+
+// SetLayout sets layout of menu buttons.
+// This function returns the receiver for convenient chaining.
+func (m *Menu) SetLayout(layout Layout) *Menu {
+	m.Layout = layout
+	return m
+}
+
+// Markup renders menu as inline keyboard.
+func (m *Menu) Markup() tgbotapi.InlineKeyboardMarkup {
+	buttons := []tgbotapi.InlineKeyboardButton{}
+	for _, item := range m.items {
+		buttons = append(buttons, m.Route.Button(item.text, item.key))
+	}
+	return tgbotapi.NewInlineKeyboardMarkup(m.Layout.Wrap(buttons)...)
+}
+
+// Handler creates a handler which calls handle function of pressed item.
+func (m *Menu) Handler() *Handler {
+	return m.Route.Handler(
+		func(u *Update) bool {
+			_, ok := m.byKey[u.Context["payload"].(string)]
+			return ok
+		},
+		func(u *Update) {
+			m.items[m.byKey[u.Context["payload"].(string)]].handle(u)
+		},
+	)
+}
+
+// ListItem is an item of paginated list.
+type ListItem struct {
+	Text  string
+	Value string
+}
+
+// ListFunc returns a page of items (starting at offset, up to limit items) & total number of items.
+type ListFunc func(u *Update, offset int, limit int) (items []ListItem, total int)
+
+type pagerAction struct {
+	Action string
+	Page   int
+	Value  string
+}
+
+// Pager is an inline keyboard which displays a paginated list of items with "previous" & "next" buttons.
+// Pressing navigation buttons re-renders the keyboard of the same message.
+// When an item is pressed, select handle functions are called with u.Context["item"] set to item value
+// and u.Context["page"] set to current page.
+type Pager struct {
+	Route    *CallbackRoute
+	Layout   Layout
+	PageSize int
+	List     ListFunc
+	Select   []HandleFunc
+	// PrevText & NextText are texts of navigation buttons.
+	PrevText string
+	NextText string
+}
+
+// NewPager creates a paginated list. name must be unique among callback routes (see NewCallbackRoute).
+// Since item values may be long, callback data which does not fit into MaxCallbackData is kept in LocalCallbackStore,
+// use Route.SetStore to change this. Panics if pageSize is not positive.
+func NewPager(name string, pageSize int, list ListFunc, selects ...HandleFunc) *Pager {
+	if pageSize <= 0 {
+		panic(fmt.Sprintf("invalid page size %d of pager %q", pageSize, name))
+	}
+	return &Pager{
+		Route:    NewCallbackRoute(name, pagerAction{}).SetStore(NewLocalCallbackStore()),
+		Layout:   Layout{Columns: 1},
+		PageSize: pageSize,
+		List:     list,
+		Select:   selects,
+		PrevText: "« Prev",
+		NextText: "Next »",
+	}
+}
+
+// SetLayout sets layout of item buttons. Navigation buttons always occupy a separate row.
+// This function returns the receiver for convenient chaining.
+func (p *Pager) SetLayout(layout Layout) *Pager {
+	p.Layout = layout
+	return p
+}
+
+// Markup renders a page (starting from 0) of the list as inline keyboard.
+func (p *Pager) Markup(u *Update, page int) tgbotapi.InlineKeyboardMarkup {
+	items, total := p.List(u, page*p.PageSize, p.PageSize)
+	buttons := []tgbotapi.InlineKeyboardButton{}
+	for _, item := range items {
+		buttons = append(buttons, p.Route.Button(item.Text, pagerAction{"select", page, item.Value}))
+	}
+	rows := p.Layout.Wrap(buttons)
+	pages := (total + p.PageSize - 1) / p.PageSize
+	if pages > 1 {
+		navigation := []tgbotapi.InlineKeyboardButton{}
+		if page > 0 {
+			navigation = append(navigation, p.Route.Button(p.PrevText, pagerAction{"page", page - 1, ""}))
+		}
+		navigation = append(navigation, p.Route.Button(fmt.Sprintf("%d/%d", page+1, pages), pagerAction{"noop", page, ""}))
+		if page < pages-1 {
+			navigation = append(navigation, p.Route.Button(p.NextText, pagerAction{"page", page + 1, ""}))
+		}
+		rows = append(rows, navigation)
+	}
+	return tgbotapi.NewInlineKeyboardMarkup(rows...)
+}
+
+// validPage checks page number received from callback data, which can be forged unless the route is signed.
+func (p *Pager) validPage(u *Update, page int) bool {
+	if page <= 0 {
+		return page == 0
+	}
+	_, total := p.List(u, 0, 0)
+	return page < (total+p.PageSize-1)/p.PageSize
+}
+
+// Handler creates a handler for navigation & item buttons.
+// Buttons with page numbers which are out of range (e. g. after the list has shrunk) are answered and ignored.
+func (p *Pager) Handler() *Handler {
+	return p.Route.Handler(nil, func(u *Update) {
+		action := u.Context["payload"].(pagerAction)
+		if (action.Action == "select" || action.Action == "page") && !p.validPage(u, action.Page) {
+			u.AnswerCallback("", false)
+			return
+		}
+		switch action.Action {
+		case "select":
+			u.Context["item"] = action.Value
+			u.Context["page"] = action.Page
+			for i := 0; i < len(p.Select) && !u.Consumed; i++ {
+				p.Select[i](u)
+			}
+		case "page":
+			message := u.CallbackQuery.Message
+			if u.Bot == nil || message == nil || message.Chat == nil {
+				return
+			}
+			edit := tgbotapi.NewEditMessageReplyMarkup(message.Chat.ID, message.MessageID, p.Markup(u, action.Page))
+			if _, err := u.Bot.Request(edit); err != nil {
+				log.Printf("Failed to switch page of %s: %s", p.Route.Name, err)
+			}
+			u.AnswerCallback("", false)
+		default:
+			u.AnswerCallback("", false)
+		}
+	})
+}
@@ -0,0 +1,143 @@
+package telemux_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+
+	tm "github.com/and3rson/telemux/v2"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+func buttonTexts(markup tgbotapi.InlineKeyboardMarkup) string {
+	rows := []string{}
+	for _, row := range markup.InlineKeyboard {
+		texts := []string{}
+		for _, button := range row {
+			texts = append(texts, button.Text)
+		}
+		rows = append(rows, strings.Join(texts, ","))
+	}
+	return strings.Join(rows, "|")
+}
+
+func TestLayout(t *testing.T) {
+	buttons := []tgbotapi.InlineKeyboardButton{}
+	for _, text := range []string{"a", "bb", "ccc", "dddddddddd", "e"} {
+		buttons = append(buttons, tgbotapi.NewInlineKeyboardButtonData(text, text))
+	}
+	markup := tgbotapi.NewInlineKeyboardMarkup(tm.Layout{Columns: 2}.Wrap(buttons)...)
+	assert(buttonTexts(markup) == "a,bb|ccc,dddddddddd|e", t, buttonTexts(markup))
+	markup = tgbotapi.NewInlineKeyboardMarkup(tm.Layout{MaxRowWidth: 6}.Wrap(buttons)...)
+	assert(buttonTexts(markup) == "a,bb,ccc|dddddddddd|e", t, buttonTexts(markup))
+	markup = tgbotapi.NewInlineKeyboardMarkup(tm.Layout{}.Wrap(buttons)...)
+	assert(buttonTexts(markup) == "a,bb,ccc,dddddddddd,e", t, buttonTexts(markup))
+}
+
+func TestMenu(t *testing.T) {
+	pressed := ""
+	menu := tm.NewMenu("settings").
+		SetLayout(tm.Layout{Columns: 2}).
+		AddItem("Language", func(u *tm.Update) { pressed = "language" }).
+		AddItem("Notifications", func(u *tm.Update) { pressed = "notifications" }).
+		AddKeyedItem("privacy", "Privacy", func(u *tm.Update) { pressed = "privacy" })
+	mux := tm.NewMux().AddKeyboard(menu)
+
+	markup := menu.Markup()
+	assert(buttonTexts(markup) == "Language,Notifications|Privacy", t, buttonTexts(markup))
+	data := *markup.InlineKeyboard[1][0].CallbackData
+	assert(mux.Dispatch(nil, tgbotapi.Update{CallbackQuery: &tgbotapi.CallbackQuery{Data: data}}), t)
+	assert(pressed == "privacy", t, pressed)
+	assert(!mux.Dispatch(nil, tgbotapi.Update{CallbackQuery: &tgbotapi.CallbackQuery{Data: `settings:"Unknown"`}}), t)
+
+	// Buttons of already sent menus keep working after items are reordered
+	reordered := tm.NewMenu("settings").
+		AddKeyedItem("privacy", "Privacy & security", func(u *tm.Update) { pressed = "new privacy" }).
+		AddItem("Language", func(u *tm.Update) { pressed = "new language" })
+	mux = tm.NewMux().AddKeyboard(reordered)
+	assert(mux.Dispatch(nil, tgbotapi.Update{CallbackQuery: &tgbotapi.CallbackQuery{Data: data}}), t)
+	assert(pressed == "new privacy", t, pressed)
+	data = *menu.Markup().InlineKeyboard[0][0].CallbackData
+	assert(mux.Dispatch(nil, tgbotapi.Update{CallbackQuery: &tgbotapi.CallbackQuery{Data: data}}), t)
+	assert(pressed == "new language", t, pressed)
+
+	func() {
+		defer func() { assert(recover() != nil, t, "Expected panic") }()
+		tm.NewMenu("dup").AddItem("A", nil).AddKeyedItem("A", "B", nil)
+	}()
+}
+
+func TestPager(t *testing.T) {
+	bot, client := newFakeBot(t)
+	all := []tm.ListItem{}
+	for i := 0; i < 7; i++ {
+		all = append(all, tm.ListItem{Text: fmt.Sprintf("Item %d", i), Value: strings.Repeat("v", 50) + fmt.Sprint(i)})
+	}
+	list := func(u *tm.Update, offset int, limit int) ([]tm.ListItem, int) {
+		end := offset + limit
+		if end > len(all) {
+			end = len(all)
+		}
+		return all[offset:end], len(all)
+	}
+	var selected interface{}
+	pager := tm.NewPager("items", 3, list, func(u *tm.Update) { selected = u.Context["item"] })
+	mux := tm.NewMux().AddKeyboard(pager)
+
+	markup := pager.Markup(&tm.Update{}, 0)
+	assert(buttonTexts(markup) == "Item 0|Item 1|Item 2|1/3,Next »", t, buttonTexts(markup))
+	markup = pager.Markup(&tm.Update{}, 2)
+	assert(buttonTexts(markup) == "Item 6|« Prev,3/3", t, buttonTexts(markup))
+	markup = pager.Markup(&tm.Update{}, 1)
+	assert(buttonTexts(markup) == "Item 3|Item 4|Item 5|« Prev,2/3,Next »", t, buttonTexts(markup))
+
+	press := func(button tgbotapi.InlineKeyboardButton) bool {
+		return mux.Dispatch(bot, tgbotapi.Update{CallbackQuery: &tgbotapi.CallbackQuery{
+			ID:      "q",
+			Data:    *button.CallbackData,
+			Message: &tgbotapi.Message{MessageID: 5, Chat: &tgbotapi.Chat{ID: 10}},
+		}})
+	}
+
+	assert(press(markup.InlineKeyboard[1][0]), t)
+	assert(selected == strings.Repeat("v", 50)+"4", t, selected)
+
+	client.Requests = nil
+	assert(press(markup.InlineKeyboard[3][2]), t)
+	assert(len(client.Requests) == 2, t, client.Requests)
+	edit := client.Requests[0]
+	assert(edit.Method == "editMessageReplyMarkup" && edit.Params.Get("message_id") == "5", t, edit)
+	var rendered tgbotapi.InlineKeyboardMarkup
+	assert(json.Unmarshal([]byte(edit.Params.Get("reply_markup")), &rendered) == nil, t)
+	assert(buttonTexts(rendered) == "Item 6|« Prev,3/3", t, buttonTexts(rendered))
+	assert(client.Requests[1].Method == "answerCallbackQuery", t, client.Requests[1])
+
+	client.Requests = nil
+	assert(press(markup.InlineKeyboard[3][1]), t)
+	assert(len(client.Requests) == 1 && client.Requests[0].Method == "answerCallbackQuery", t, client.Requests)
+
+	// Forged page numbers are rejected before calling list
+	selected = nil
+	for _, data := range []string{`items:["page",-3,""]`, `items:["page",3,""]`, `items:["select",-1,"x"]`, `items:["select",7,"x"]`} {
+		client.Requests = nil
+		assert(mux.Dispatch(bot, tgbotapi.Update{CallbackQuery: &tgbotapi.CallbackQuery{
+			ID:      "q",
+			Data:    data,
+			Message: &tgbotapi.Message{MessageID: 5, Chat: &tgbotapi.Chat{ID: 10}},
+		}}), t, data)
+		assert(len(client.Requests) == 1 && client.Requests[0].Method == "answerCallbackQuery", t, data, client.Requests)
+		assert(selected == nil, t, data, selected)
+	}
+
+	// Pages are not switched without bot
+	assert(mux.Dispatch(nil, tgbotapi.Update{CallbackQuery: &tgbotapi.CallbackQuery{
+		Data:    *markup.InlineKeyboard[3][2].CallbackData,
+		Message: &tgbotapi.Message{MessageID: 5, Chat: &tgbotapi.Chat{ID: 10}},
+	}}), t)
+
+	func() {
+		defer func() { assert(recover() != nil, t, "Expected panic") }()
+		tm.NewPager("empty", 0, list)
+	}()
+}
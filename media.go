@@ -0,0 +1,100 @@
+package telemux
+
+import (
+	"path"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// fileSize returns size of a file attached to message and false if message has no file.
+// For photos, the largest size is used.
+func fileSize(message *tgbotapi.Message) (int, bool) {
+	switch {
+	case message.Document != nil:
+		return message.Document.FileSize, true
+	case message.Video != nil:
+		return message.Video.FileSize, true
+	case message.Audio != nil:
+		return message.Audio.FileSize, true
+	case message.Voice != nil:
+		return message.Voice.FileSize, true
+	case message.Animation != nil:
+		return message.Animation.FileSize, true
+	case message.VideoNote != nil:
+		return message.VideoNote.FileSize, true
+	case message.Sticker != nil:
+		return message.Sticker.FileSize, true
+	case len(message.Photo) > 0:
+		return message.Photo[len(message.Photo)-1].FileSize, true
+	}
+	return 0, false
+}
+
+// DocumentMIME filters updates that contain a document with one of MIME types.
+// Patterns may contain wildcards, e. g. DocumentMIME("application/pdf", "image/*").
+func DocumentMIME(patterns ...string) FilterFunc {
+	lowered := make([]string, len(patterns))
+	for i, pattern := range patterns {
+		lowered[i] = strings.ToLower(pattern)
+	}
+	return messageFilter(func(u *Update, message *tgbotapi.Message) bool {
+		if message.Document == nil {
+			return false
+		}
+		mimeType := strings.ToLower(message.Document.MimeType)
+		for _, pattern := range lowered {
+			if ok, _ := path.Match(pattern, mimeType); ok {
+				return true
+			}
+		}
+		return false
+	})
+}
+
+// DocumentExtension filters updates that contain a document with one of file name extensions (case-insensitive),
+// e. g. DocumentExtension("pdf", ".docx").
+func DocumentExtension(extensions ...string) FilterFunc {
+	set := make(map[string]bool)
+	for _, extension := range extensions {
+		set["."+strings.ToLower(strings.TrimPrefix(extension, "."))] = true
+	}
+	return messageFilter(func(u *Update, message *tgbotapi.Message) bool {
+		return message.Document != nil && set[strings.ToLower(path.Ext(message.Document.FileName))]
+	})
+}
+
+// MaxFileSize filters updates that contain a file (document, photo, video, audio etc) not larger than size bytes.
+// Files with unknown size are accepted.
+func MaxFileSize(size int) FilterFunc {
+	return messageFilter(func(u *Update, message *tgbotapi.Message) bool {
+		actual, ok := fileSize(message)
+		return ok && actual <= size
+	})
+}
+
+// VideoMaxDuration filters updates that contain a video or a video note not longer than duration.
+func VideoMaxDuration(duration time.Duration) FilterFunc {
+	return messageFilter(func(u *Update, message *tgbotapi.Message) bool {
+		switch {
+		case message.Video != nil:
+			return time.Duration(message.Video.Duration)*time.Second <= duration
+		case message.VideoNote != nil:
+			return time.Duration(message.VideoNote.Duration)*time.Second <= duration
+		}
+		return false
+	})
+}
+
+// PhotoMinResolution filters updates that contain a photo which is at least width x height pixels large.
+func PhotoMinResolution(width, height int) FilterFunc {
+	return messageFilter(func(u *Update, message *tgbotapi.Message) bool {
+		for _, size := range message.Photo {
+			if size.Width >= width && size.Height >= height {
+				return true
+			}
+		}
+		return false
+	})
+}
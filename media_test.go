@@ -0,0 +1,53 @@
+package telemux_test
+
+import (
+	"testing"
+	"time"
+
+	tm "github.com/and3rson/telemux/v2"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+func TestDocumentFilters(t *testing.T) {
+	u := &tm.Update{}
+	assert(!tm.DocumentMIME("*/*")(u), t)
+	u.Message = &tgbotapi.Message{}
+	assert(!tm.DocumentMIME("*/*")(u), t)
+	assert(!tm.DocumentExtension("pdf")(u), t)
+	assert(!tm.MaxFileSize(100)(u), t)
+
+	u.Message.Document = &tgbotapi.Document{FileName: "Report.PDF", MimeType: "application/pdf", FileSize: 100}
+	assert(tm.DocumentMIME("image/*", "application/pdf")(u), t)
+	assert(!tm.DocumentMIME("image/*")(u), t)
+	assert(tm.DocumentExtension(".docx", "pdf")(u), t)
+	assert(!tm.DocumentExtension("docx")(u), t)
+	assert(tm.MaxFileSize(100)(u), t)
+	assert(!tm.MaxFileSize(99)(u), t)
+	assert(tm.And(tm.HasDocument(), tm.DocumentMIME("application/*"), tm.Not(tm.MaxFileSize(50)))(u), t)
+
+	u.Message.Document.MimeType = "IMAGE/JPEG"
+	assert(tm.DocumentMIME("image/*")(u), t)
+
+	u.CallbackQuery, u.Message = &tgbotapi.CallbackQuery{Message: u.Message}, nil
+	assert(!tm.DocumentMIME("image/*")(u), t)
+}
+
+func TestVideoAndPhotoFilters(t *testing.T) {
+	u := &tm.Update{}
+	u.Message = &tgbotapi.Message{Video: &tgbotapi.Video{Duration: 60, FileSize: 1000}}
+	assert(tm.VideoMaxDuration(time.Minute)(u), t)
+	assert(!tm.VideoMaxDuration(59*time.Second)(u), t)
+	assert(tm.MaxFileSize(1000)(u), t)
+
+	u.Message = &tgbotapi.Message{VideoNote: &tgbotapi.VideoNote{Duration: 10}}
+	assert(tm.VideoMaxDuration(10*time.Second)(u), t)
+
+	u.Message = &tgbotapi.Message{Photo: []tgbotapi.PhotoSize{
+		{Width: 90, Height: 60, FileSize: 10},
+		{Width: 1280, Height: 720, FileSize: 1000},
+	}}
+	assert(!tm.VideoMaxDuration(time.Hour)(u), t)
+	assert(tm.PhotoMinResolution(1280, 720)(u), t)
+	assert(!tm.PhotoMinResolution(1920, 1080)(u), t)
+	assert(!tm.MaxFileSize(999)(u), t)
+}
@@ -0,0 +1,92 @@
+package telemux
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// DefaultMediaGroupQuietPeriod is a quiet period used by NewMediaGroupHandler.
+var DefaultMediaGroupQuietPeriod = time.Second
+
+type mediaGroupBuffer struct {
+	update   *Update
+	messages []*tgbotapi.Message
+	timer    *time.Timer
+}
+
+// NewMediaGroupHandler creates a handler for albums (media groups) with DefaultMediaGroupQuietPeriod.
+// See NewMediaGroupHandlerWithQuietPeriod.
+func NewMediaGroupHandler(filter FilterFunc, handle HandleFunc) *Handler {
+	return NewMediaGroupHandlerWithQuietPeriod(DefaultMediaGroupQuietPeriod, filter, handle)
+}
+
+// NewMediaGroupHandlerWithQuietPeriod creates a handler for albums (media groups).
+//
+// Telegram delivers album as several updates which share MediaGroupID. This handler buffers such updates
+// until no new items arrive for quietPeriod and then calls handle once with the first update of the album.
+// u.Context["media_group"] is set to a slice of all album messages ([]*tgbotapi.Message) ordered by message ID.
+// Messages which do not belong to an album are handled immediately as albums with a single item.
+//
+// Handle is called from a timer goroutine after the quiet period. For updates passed to Mux.Dispatch, it does not run
+// concurrently with Dispatch of the same multiplexer (Dispatch waits for it to finish), so handle can use the same
+// data as other handlers without additional locking. Handle is not traced in debug mode. Panics in handle are logged.
+// When used as a conversation state handler, u.PersistenceContext of the first update is available in handle,
+// so it can change conversation state (however, ":enter" hooks are not called).
+func NewMediaGroupHandlerWithQuietPeriod(quietPeriod time.Duration, filter FilterFunc, handle HandleFunc) *Handler {
+	var mutex sync.Mutex
+	buffers := make(map[string]*mediaGroupBuffer)
+
+	flush := func(key string, buffer *mediaGroupBuffer) {
+		mutex.Lock()
+		if buffers[key] != buffer {
+			// Already flushed
+			mutex.Unlock()
+			return
+		}
+		delete(buffers, key)
+		mutex.Unlock()
+		sort.Slice(buffer.messages, func(i, j int) bool {
+			return buffer.messages[i].MessageID < buffer.messages[j].MessageID
+		})
+		u := buffer.update
+		u.setContext("media_group", buffer.messages)
+		u.runDeferred("media group", handle)
+	}
+
+	newFilter := And(Not(IsCallbackQuery()), func(u *Update) bool {
+		return u.EffectiveMessage() != nil
+	})
+	if filter != nil {
+		newFilter = And(newFilter, filter)
+	}
+	return NewHandler(newFilter, func(u *Update) {
+		message := u.EffectiveMessage()
+		if message.MediaGroupID == "" {
+			u.setContext("media_group", []*tgbotapi.Message{message})
+			handle(u)
+			return
+		}
+		var chatID int64
+		if message.Chat != nil {
+			chatID = message.Chat.ID
+		}
+		key := fmt.Sprintf("%d:%s", chatID, message.MediaGroupID)
+		mutex.Lock()
+		defer mutex.Unlock()
+		if buffer, ok := buffers[key]; ok {
+			buffer.messages = append(buffer.messages, message)
+			buffer.timer.Reset(quietPeriod)
+			return
+		}
+		buffer := &mediaGroupBuffer{
+			update:   u.detach(),
+			messages: []*tgbotapi.Message{message},
+		}
+		buffer.timer = time.AfterFunc(quietPeriod, func() { flush(key, buffer) })
+		buffers[key] = buffer
+	})
+}
@@ -0,0 +1,121 @@
+package telemux_test
+
+import (
+	"testing"
+	"time"
+
+	tm "github.com/and3rson/telemux/v2"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+func TestMediaGroupHandler(t *testing.T) {
+	NewTGUpdate := func(id int, group string) tgbotapi.Update {
+		u := tgbotapi.Update{}
+		u.Message = &tgbotapi.Message{
+			MessageID:    id,
+			MediaGroupID: group,
+			Photo:        []tgbotapi.PhotoSize{{FileID: "photo"}},
+			From:         &tgbotapi.User{ID: 1},
+			Chat:         &tgbotapi.Chat{ID: 1},
+		}
+		return u
+	}
+
+	albums := make(chan []int, 10)
+	handler := tm.NewMediaGroupHandlerWithQuietPeriod(50*time.Millisecond, tm.HasPhoto(), func(u *tm.Update) {
+		ids := []int{}
+		for _, message := range u.Context["media_group"].([]*tgbotapi.Message) {
+			ids = append(ids, message.MessageID)
+		}
+		albums <- ids
+	})
+	mux := tm.NewMux().AddHandler(handler)
+
+	assert(mux.Dispatch(nil, NewTGUpdate(2, "a")), t)
+	assert(mux.Dispatch(nil, NewTGUpdate(5, "b")), t)
+	assert(mux.Dispatch(nil, NewTGUpdate(1, "a")), t)
+	assert(mux.Dispatch(nil, NewTGUpdate(3, "a")), t)
+	select {
+	case <-albums:
+		t.Error("album should not be flushed yet")
+	default:
+	}
+	assert(mux.Dispatch(nil, NewTGUpdate(7, "")), t)
+	assert(len(<-albums) == 1, t)
+
+	received := map[int]int{}
+	for i := 0; i < 2; i++ {
+		select {
+		case ids := <-albums:
+			received[ids[0]] = len(ids)
+			if ids[0] == 1 {
+				assert(ids[1] == 2 && ids[2] == 3, t, ids)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("album was not flushed")
+		}
+	}
+	assert(received[1] == 3 && received[5] == 1, t, received)
+
+	u := NewTGUpdate(8, "")
+	u.Message.Photo = nil
+	assert(!mux.Dispatch(nil, u), t)
+
+	// Deferred handling is not traced
+	traced := make(chan bool, 1)
+	mux = tm.NewMux().SetDebug(true).AddHandler(tm.NewMediaGroupHandlerWithQuietPeriod(10*time.Millisecond, nil, func(u *tm.Update) {
+		traced <- u.Trace != nil
+	}))
+	assert(mux.Dispatch(nil, NewTGUpdate(9, "c")), t)
+	assert(!<-traced, t)
+}
+
+func TestMediaGroupConversation(t *testing.T) {
+	NewTGUpdate := func(id int, text string, group string) tgbotapi.Update {
+		u := tgbotapi.Update{}
+		u.Message = &tgbotapi.Message{MessageID: id, Text: text, MediaGroupID: group, From: &tgbotapi.User{ID: 1}, Chat: &tgbotapi.Chat{ID: 1}}
+		if group != "" {
+			u.Message.Photo = []tgbotapi.PhotoSize{{FileID: "photo"}}
+		}
+		return u
+	}
+
+	persistence := tm.NewLocalPersistence()
+	done := make(chan int, 1)
+	mux := tm.NewMux().AddHandler(tm.NewConversationHandler(
+		"album",
+		persistence,
+		tm.StateMap{
+			"": {tm.NewCommandHandler("upload", nil, func(u *tm.Update) {
+				u.PersistenceContext.SetState("wait_album")
+			})},
+			"wait_album": {tm.NewMediaGroupHandlerWithQuietPeriod(50*time.Millisecond, tm.HasPhoto(), func(u *tm.Update) {
+				u.PersistenceContext.PutDataValue("count", len(u.Context["media_group"].([]*tgbotapi.Message)))
+				u.PersistenceContext.SetState("")
+				done <- 1
+			})},
+		},
+		nil,
+	))
+
+	assert(mux.Dispatch(nil, NewTGUpdate(1, "/upload", "")), t)
+	assert(mux.Dispatch(nil, NewTGUpdate(2, "", "g")), t)
+	assert(mux.Dispatch(nil, NewTGUpdate(3, "", "g")), t)
+	// Other users keep using the same persistence while the album is handled (run with -race)
+	deadline := time.After(time.Second)
+	for userID, waiting := int64(2), true; waiting; userID++ {
+		select {
+		case <-done:
+			waiting = false
+		case <-deadline:
+			t.Fatal("album was not flushed")
+		default:
+			other := NewTGUpdate(4, "/upload", "")
+			other.Message.From.ID = userID
+			assert(mux.Dispatch(nil, other), t)
+		}
+	}
+	pk := tm.PersistenceKey{ConversationID: "album", UserID: 1, ChatID: 1}
+	assert(persistence.GetState(pk) == "", t, persistence.GetState(pk))
+	assert(persistence.GetData(pk)["count"] == 2, t, persistence.GetData(pk))
+}
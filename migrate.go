@@ -0,0 +1,101 @@
+package telemux
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// ErrNotIterable is returned when a persistence does not implement Iterable interface.
+var ErrNotIterable = errors.New("persistence does not implement Iterable")
+
+// Record is a snapshot of a single conversation. It is used to export & import conversations.
+type Record struct {
+	Key   PersistenceKey `json:"key"`
+	State string         `json:"state"`
+	Data  Data           `json:"data"`
+}
+
+// Records returns snapshots of all conversations stored in persistence.
+// Persistence must implement Iterable interface.
+func Records(p ConversationPersistence) ([]Record, error) {
+	iterable, ok := asIterable(p)
+	if !ok {
+		return nil, ErrNotIterable
+	}
+	keys, err := iterable.Keys()
+	if err != nil {
+		return nil, err
+	}
+	records := make([]Record, 0, len(keys))
+	for _, pk := range keys {
+		records = append(records, Record{pk, p.GetState(pk), p.GetData(pk)})
+	}
+	return records, nil
+}
+
+// Restore writes conversation record into persistence.
+func (r Record) Restore(p ConversationPersistence) {
+	data := r.Data
+	if data == nil {
+		data = make(Data)
+	}
+	p.SetState(r.Key, r.State)
+	p.SetData(r.Key, data)
+}
+
+// Migrate copies states & data of all conversations from src to dst.
+// It is useful when switching from one persistence to another (e. g. from FilePersistence to GORMPersistence)
+// without losing conversations which are currently in progress.
+//
+// src must implement Iterable interface. Existing conversations in dst with same keys are overwritten.
+func Migrate(src, dst ConversationPersistence) error {
+	records, err := Records(src)
+	if err != nil {
+		return err
+	}
+	for _, record := range records {
+		record.Restore(dst)
+	}
+	return nil
+}
+
+// Export writes all conversations from persistence into w as JSON Lines, one Record per line.
+// Persistence must implement Iterable interface.
+func Export(p ConversationPersistence, w io.Writer) error {
+	records, err := Records(p)
+	if err != nil {
+		return err
+	}
+	encoder := json.NewEncoder(w)
+	for _, record := range records {
+		if err := encoder.Encode(record); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Import reads conversations from r as JSON Lines (see Export) and writes them into persistence.
+// Returns number of imported records.
+func Import(p ConversationPersistence, r io.Reader) (int, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	count := 0
+	line := 0
+	for scanner.Scan() {
+		line++
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var record Record
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			return count, fmt.Errorf("line %d: %w", line, err)
+		}
+		record.Restore(p)
+		count++
+	}
+	return count, scanner.Err()
+}
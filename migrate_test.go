@@ -0,0 +1,116 @@
+package telemux_test
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	tm "github.com/and3rson/telemux/v2"
+)
+
+type nonIterablePersistence struct {
+	tm.ConversationPersistence
+}
+
+type failingIterablePersistence struct {
+	tm.ConversationPersistence
+}
+
+func (p failingIterablePersistence) Keys() ([]tm.PersistenceKey, error) {
+	return nil, errors.New("database is not available")
+}
+
+func TestKeys(t *testing.T) {
+	p := tm.NewLocalPersistence()
+	keys, err := p.Keys()
+	assert(err == nil && len(keys) == 0, t, keys, err)
+	p.SetState(tm.PersistenceKey{"b", 1, 2}, "foo")
+	p.SetData(tm.PersistenceKey{"a", 3, 4}, tm.Data{"foo": "bar"})
+	p.SetData(tm.PersistenceKey{"b", 1, 2}, tm.Data{})
+	keys, err = p.Keys()
+	assert(err == nil && reflect.DeepEqual(keys, []tm.PersistenceKey{{"a", 3, 4}, {"b", 1, 2}}), t, keys, err)
+}
+
+func TestMigrate(t *testing.T) {
+	src := tm.NewLocalPersistence()
+	dst := tm.NewLocalPersistence()
+	pk1 := tm.PersistenceKey{"foo", 1, 2}
+	pk2 := tm.PersistenceKey{"bar", 3, 4}
+	src.SetState(pk1, "state1")
+	src.SetData(pk1, tm.Data{"name": "Foobar"})
+	src.SetState(pk2, "state2")
+
+	assert(tm.Migrate(src, dst) == nil, t)
+	assert(dst.GetState(pk1) == "state1", t)
+	assert(dst.GetState(pk2) == "state2", t)
+	assert(reflect.DeepEqual(dst.GetData(pk1), tm.Data{"name": "Foobar"}), t)
+	assert(reflect.DeepEqual(dst.GetData(pk2), tm.Data{}), t)
+
+	assert(tm.Migrate(nonIterablePersistence{src}, dst) == tm.ErrNotIterable, t)
+}
+
+func TestExportImport(t *testing.T) {
+	src := tm.NewLocalPersistence()
+	src.SetState(tm.PersistenceKey{"foo:bar", 1, 2}, "state1")
+	src.SetData(tm.PersistenceKey{"foo:bar", 1, 2}, tm.Data{"name": "Foobar"})
+
+	buf := &bytes.Buffer{}
+	assert(tm.Export(src, buf) == nil, t)
+	assert(buf.String() == `{"key":"foo:bar:1:2","state":"state1","data":{"name":"Foobar"}}`+"\n", t, buf.String())
+
+	dst := tm.NewLocalPersistence()
+	count, err := tm.Import(dst, strings.NewReader(buf.String()+"\n"+`{"key":"baz:3:4","state":"state2"}`+"\n"))
+	assert(err == nil, t, err)
+	assert(count == 2, t)
+	assert(dst.GetState(tm.PersistenceKey{"foo:bar", 1, 2}) == "state1", t)
+	assert(reflect.DeepEqual(dst.GetData(tm.PersistenceKey{"foo:bar", 1, 2}), tm.Data{"name": "Foobar"}), t)
+	assert(dst.GetState(tm.PersistenceKey{"baz", 3, 4}) == "state2", t)
+
+	_, err = tm.Import(dst, strings.NewReader(`{"key":"baz:x:4"}`))
+	assert(err != nil && strings.HasPrefix(err.Error(), "line 1:"), t, err)
+}
+
+func TestMigrateDecorators(t *testing.T) {
+	keyring, _ := tm.NewKeyring("k1", bytes.Repeat([]byte{1}, 16))
+	pk1 := tm.PersistenceKey{"foo", 1, 2}
+	pk2 := tm.PersistenceKey{"foo", 3, 4}
+	cached := tm.NewCachedPersistence(tm.NewLocalPersistence(), tm.CacheOptions{WriteBehind: true})
+	defer cached.Close()
+	for _, src := range []tm.ConversationPersistence{
+		tm.NewEncryptedPersistence(tm.NewLocalPersistence(), keyring),
+		tm.NewVersionedPersistence(tm.NewLocalPersistence(), 2),
+		cached,
+	} {
+		src.SetState(pk1, "state1")
+		src.SetData(pk2, tm.Data{"name": "Foobar"})
+		dst := tm.NewLocalPersistence()
+		assert(tm.Migrate(src, dst) == nil, t, src)
+		assert(dst.GetState(pk1) == "state1", t, src)
+		assert(dst.GetData(pk2)["name"] == "Foobar", t, src)
+	}
+
+	// Decorators are iterable only if wrapped persistence is iterable
+	nonIterable := nonIterablePersistence{tm.NewLocalPersistence()}
+	for _, src := range []tm.ConversationPersistence{
+		tm.NewEncryptedPersistence(nonIterable, keyring),
+		tm.NewVersionedPersistence(tm.NewCachedPersistence(nonIterable, tm.CacheOptions{}), 2),
+	} {
+		assert(tm.Migrate(src, tm.NewLocalPersistence()) == tm.ErrNotIterable, t, src)
+	}
+	assert(tm.NewEncryptedPersistence(nonIterable, keyring).Rotate() == tm.ErrNotIterable, t)
+
+	// Errors of wrapped persistence are passed through
+	failing := failingIterablePersistence{tm.NewLocalPersistence()}
+	cachedFailing := tm.NewCachedPersistence(failing, tm.CacheOptions{})
+	for _, src := range []tm.ConversationPersistence{
+		failing,
+		tm.NewEncryptedPersistence(failing, keyring),
+		tm.NewVersionedPersistence(cachedFailing, 2),
+	} {
+		err := tm.Migrate(src, tm.NewLocalPersistence())
+		assert(err != nil && err.Error() == "database is not available", t, src, err)
+	}
+	assert(tm.NewEncryptedPersistence(failing, keyring).Rotate() != nil, t)
+}
@@ -6,7 +6,10 @@ package telemux
 
 import (
 	"fmt"
+	"log"
 	"runtime/debug"
+	"sync"
+	"time"
 
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
 )
@@ -21,9 +24,21 @@ type Processor interface {
 
 // Mux contains handlers, nested multiplexers and global filter.
 type Mux struct {
+	Name         string      // Used as a title of command group in help (see NewHelpHandler)
 	Processors   []Processor // Contains instances of Mux & Handler
 	Recover      RecoverFunc
 	GlobalFilter FilterFunc
+	DataStore    DataStore
+	Debug        bool
+	TraceFunc    func(u *Update)
+	MaxAge       time.Duration
+	StaleHandler *Handler
+	AutoAnswer   bool
+	ReplyOptions []ReplyOption
+	RateLimiter  *RateLimiter
+
+	// dispatching is read-locked by Dispatch & locked by deferred handlers (see NewMediaGroupHandler)
+	dispatching sync.RWMutex
 }
 
 // NewMux creates new multiplexer.
@@ -49,6 +64,13 @@ func (m *Mux) AddMux(others ...*Mux) *Mux {
 	return m
 }
 
+// SetName sets name of multiplexer which is used as a title of its command group in help (see NewHelpHandler).
+// This function returns the receiver for convenient chaining.
+func (m *Mux) SetName(name string) *Mux {
+	m.Name = name
+	return m
+}
+
 // SetGlobalFilter sets a filter to be called for every update before any other filters.
 // This function returns the receiver for convenient chaining.
 func (m *Mux) SetGlobalFilter(filter FilterFunc) *Mux {
@@ -63,6 +85,79 @@ func (m *Mux) SetRecover(recover RecoverFunc) *Mux {
 	return m
 }
 
+// SetDataStore registers a store for user, chat & bot data (see Update.UserData, Update.ChatData & Update.BotData).
+// Store is available to all handlers of this multiplexer and nested multiplexers, unless they register their own store.
+// This function returns the receiver for convenient chaining.
+func (m *Mux) SetDataStore(store DataStore) *Mux {
+	m.DataStore = store
+	return m
+}
+
+// SetDebug enables or disables debug mode.
+// In debug mode, evaluation of every filter & processor is recorded in u.Trace, which allows to find out
+// why a handler did not fire. Use Named to give meaningful names to filters in the trace.
+// This function returns the receiver for convenient chaining.
+func (m *Mux) SetDebug(debug bool) *Mux {
+	m.Debug = debug
+	return m
+}
+
+// SetTraceFunc registers a function to call with update after it was processed in debug mode, e. g. LogTrace.
+// This function returns the receiver for convenient chaining.
+func (m *Mux) SetTraceFunc(f func(u *Update)) *Mux {
+	m.TraceFunc = f
+	return m
+}
+
+// SetMaxAge makes multiplexer skip updates with messages sent (or edited) more than maxAge ago,
+// e. g. commands which were sent while the bot was offline. Stale updates are passed to staleHandler, if it is not nil,
+// and are dropped otherwise. Updates rejected by global filter never reach staleHandler.
+// Zero maxAge disables the check. See also OlderThan.
+// This function returns the receiver for convenient chaining.
+func (m *Mux) SetMaxAge(maxAge time.Duration, staleHandler *Handler) *Mux {
+	m.MaxAge = maxAge
+	m.StaleHandler = staleHandler
+	return m
+}
+
+// SetAutoAnswerCallbacks enables or disables automatic answering of callback queries.
+// When enabled, callback queries which were not answered with Update.AnswerCallback (including ones which were not handled at all)
+// are answered with empty text after processing, so the button does not keep spinning.
+// It is usually enabled on the root multiplexer. When enabled on a nested one, it applies to updates which reach it;
+// the answer is still sent after the root multiplexer finishes processing.
+// This function returns the receiver for convenient chaining.
+func (m *Mux) SetAutoAnswerCallbacks(enabled bool) *Mux {
+	m.AutoAnswer = enabled
+	return m
+}
+
+// SetReplyOptions sets default options of reply helpers (Update.Reply, Update.ReplyPhoto etc), e. g. Quote().
+// Options are applied to updates processed by this multiplexer and nested multiplexers, after options of parent multiplexers.
+// This function returns the receiver for convenient chaining.
+func (m *Mux) SetReplyOptions(opts ...ReplyOption) *Mux {
+	m.ReplyOptions = opts
+	return m
+}
+
+// SetRateLimiter limits rate of updates processed by this multiplexer (see RateLimiter).
+// Every update which passes global filter is counted, even if none of the handlers accepts it.
+// Updates which exceed the limit are not passed to handlers & nested multiplexers.
+// This function returns the receiver for convenient chaining.
+func (m *Mux) SetRateLimiter(limiter *RateLimiter) *Mux {
+	m.RateLimiter = limiter
+	return m
+}
+
+func (m *Mux) autoAnswerCallback(u *Update) {
+	u.depth--
+	if u.depth > 0 || !u.autoAnswer || u.CallbackQuery == nil || u.callbackAnswered || u.Bot == nil {
+		return
+	}
+	if err := u.AnswerCallback("", false); err != nil {
+		log.Printf("Failed to answer callback query %s: %s", u.CallbackQuery.ID, err)
+	}
+}
+
 func (m *Mux) tryRecover(u *Update) {
 	if r := recover(); r != nil {
 		err, ok := r.(error)
@@ -80,20 +175,68 @@ func (m *Mux) tryRecover(u *Update) {
 
 // Dispatch tells Mux to process the update.
 // Returns true if the update was processed by one of the handlers.
+//
+// Updates about chat member changes also invalidate DefaultAdminCache.
+//
+// Dispatch may be called from several goroutines, however it waits for deferred handlers
+// (see NewMediaGroupHandlerWithQuietPeriod & NewDebounceHandler) of this multiplexer to finish.
 func (m *Mux) Dispatch(bot *tgbotapi.BotAPI, u tgbotapi.Update) bool {
-	return m.Process(&Update{u, bot, false, nil, make(Map)})
+	m.dispatching.RLock()
+	defer m.dispatching.RUnlock()
+	update := &Update{Update: u, Bot: bot, Context: make(Map), dispatching: &m.dispatching}
+	DefaultAdminCache.Observe(update)
+	return m.Process(update)
 }
 
 // Process runs mux with provided update.
 func (m *Mux) Process(u *Update) bool {
 	defer m.tryRecover(u)
 
-	if m.GlobalFilter != nil && !m.GlobalFilter(u) {
+	u.depth++
+	u.autoAnswer = u.autoAnswer || m.AutoAnswer
+	defer m.autoAnswerCallback(u)
+
+	if m.Debug && u.Trace == nil {
+		u.Trace = &Trace{}
+		if m.TraceFunc != nil {
+			defer m.TraceFunc(u)
+		}
+	}
+
+	if m.DataStore != nil {
+		parentStore := u.DataStore
+		u.DataStore = m.DataStore
+		defer func() { u.DataStore = parentStore }()
+	}
+
+	if len(m.ReplyOptions) > 0 {
+		parentOptions := u.replyOptions
+		u.replyOptions = append(append([]ReplyOption{}, parentOptions...), m.ReplyOptions...)
+		defer func() { u.replyOptions = parentOptions }()
+	}
+
+	if m.GlobalFilter != nil && !u.evalLabeledFilter(m.GlobalFilter, "GlobalFilter") {
 		return false
 	}
 
-	for _, Processor := range m.Processors {
-		if Processor.Process(u) {
+	if m.MaxAge > 0 && u.evalLabeledFilter(OlderThan(m.MaxAge), "MaxAge") {
+		if m.StaleHandler == nil {
+			return false
+		}
+		return u.traceCall("StaleHandler", func() bool { return m.StaleHandler.Process(u) })
+	}
+
+	if m.RateLimiter != nil && !u.evalLabeledFilter(m.RateLimiter.Allow, "RateLimiter") {
+		m.RateLimiter.limit(u)
+		return true
+	}
+
+	for i, processor := range m.Processors {
+		if u.Trace == nil {
+			if processor.Process(u) {
+				return true
+			}
+		} else if u.traceCall(fmt.Sprintf("#%d %T", i, processor), func() bool { return processor.Process(u) }) {
 			return true
 		}
 	}
@@ -124,3 +124,49 @@ func TestMuxRecover(t *testing.T) {
 		mux.Dispatch(nil, NewTGUpdate("panic_error"))
 	}()
 }
+
+func TestMuxAutoAnswerCallbacks(t *testing.T) {
+	bot, client := newFakeBot(t)
+	NewTGUpdate := func(data string) tgbotapi.Update {
+		return tgbotapi.Update{CallbackQuery: &tgbotapi.CallbackQuery{ID: data, Data: data}}
+	}
+
+	mux := tm.NewMux().
+		AddMux(tm.NewMux().
+			SetAutoAnswerCallbacks(true).
+			AddHandler(tm.NewCallbackQueryHandler("^silent$", nil, func(u *tm.Update) {})).
+			AddHandler(tm.NewCallbackQueryHandler("^answer$", nil, func(u *tm.Update) {
+				assert(u.AnswerCallback("Done", true) == nil, t)
+				assert(u.CallbackAnswered(), t)
+				assert(u.AnswerCallback("Again", false) != nil, t)
+			})),
+		).
+		AddHandler(tm.NewCallbackQueryHandler("^outer$", nil, func(u *tm.Update) {
+			// Answer must be sent after the root mux finishes
+			assert(len(client.Requests) == 0, t, client.Requests)
+		}))
+
+	assert(mux.Dispatch(bot, NewTGUpdate("silent")), t)
+	assert(len(client.Requests) == 1, t, client.Requests)
+	assert(client.Requests[0].Method == "answerCallbackQuery", t, client.Requests[0])
+	assert(client.Requests[0].Params.Get("callback_query_id") == "silent", t, client.Requests[0])
+	assert(client.Requests[0].Params.Get("text") == "", t, client.Requests[0])
+
+	client.Requests = nil
+	assert(mux.Dispatch(bot, NewTGUpdate("answer")), t)
+	assert(len(client.Requests) == 1, t, client.Requests)
+	assert(client.Requests[0].Params.Get("text") == "Done", t, client.Requests[0])
+	assert(client.Requests[0].Params.Get("show_alert") == "true", t, client.Requests[0])
+
+	client.Requests = nil
+	assert(mux.Dispatch(bot, NewTGUpdate("outer")), t)
+	assert(len(client.Requests) == 1, t, client.Requests)
+
+	client.Requests = nil
+	assert(!mux.Dispatch(bot, NewTGUpdate("unknown")), t)
+	assert(len(client.Requests) == 1, t, client.Requests)
+
+	client.Requests = nil
+	assert(!tm.NewMux().Dispatch(bot, NewTGUpdate("disabled")), t)
+	assert(len(client.Requests) == 0, t, client.Requests)
+}
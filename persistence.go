@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"io/ioutil"
 	"os"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
@@ -24,6 +25,42 @@ type ConversationPersistence interface {
 	SetData(pk PersistenceKey, data Data)
 }
 
+// Iterable is an optional extension to ConversationPersistence which allows to enumerate all stored conversations.
+// It is required to migrate or export conversations (see Migrate, Export & Import).
+type Iterable interface {
+	// Keys returns all persistence keys that have state or data associated with them.
+	// Error is returned if keys cannot be read, e. g. if database is not available.
+	Keys() ([]PersistenceKey, error)
+}
+
+// decorator is implemented by persistences which wrap another persistence, e. g. CachedPersistence.
+type decorator interface {
+	inner() ConversationPersistence
+}
+
+// asIterable checks if persistence implements Iterable.
+// Decorators implement Iterable unconditionally, so persistences they wrap are checked as well.
+func asIterable(p ConversationPersistence) (Iterable, bool) {
+	iterable, ok := p.(Iterable)
+	for current := p; ok; {
+		wrapper, isDecorator := current.(decorator)
+		if !isDecorator {
+			break
+		}
+		current = wrapper.inner()
+		_, ok = current.(Iterable)
+	}
+	return iterable, ok
+}
+
+// innerKeys returns keys of persistence wrapped by decorator, or nil if it does not implement Iterable.
+func innerKeys(inner ConversationPersistence) ([]PersistenceKey, error) {
+	if iterable, ok := inner.(Iterable); ok {
+		return iterable.Keys()
+	}
+	return nil, nil
+}
+
 // PersistenceContext allows handler to get/set conversation data & change conversation state.
 type PersistenceContext struct {
 	Persistence ConversationPersistence
@@ -96,6 +133,35 @@ func (k *PersistenceKey) UnmarshalText(b []byte) error {
 	return nil
 }
 
+// SortKeys sorts persistence keys in place by conversation ID, user ID & chat ID.
+// It is useful for implementations of Iterable which store keys in maps.
+func SortKeys(keys []PersistenceKey) {
+	sort.Slice(keys, func(i, j int) bool {
+		a, b := keys[i], keys[j]
+		if a.ConversationID != b.ConversationID {
+			return a.ConversationID < b.ConversationID
+		}
+		if a.UserID != b.UserID {
+			return a.UserID < b.UserID
+		}
+		return a.ChatID < b.ChatID
+	})
+}
+
+func mergeKeys(states map[PersistenceKey]string, data map[PersistenceKey]Data) []PersistenceKey {
+	keys := make([]PersistenceKey, 0, len(states)+len(data))
+	for pk := range states {
+		keys = append(keys, pk)
+	}
+	for pk := range data {
+		if _, ok := states[pk]; !ok {
+			keys = append(keys, pk)
+		}
+	}
+	SortKeys(keys)
+	return keys
+}
+
 // LocalPersistence is an implementation of Persistence.
 // It stores conversation states & conversation data in memory.
 //
@@ -144,6 +210,11 @@ func (p *LocalPersistence) SetData(pk PersistenceKey, data Data) {
 	p.Data[pk] = data
 }
 
+// Keys returns all conversation keys stored in memory
+func (p *LocalPersistence) Keys() ([]PersistenceKey, error) {
+	return mergeKeys(p.States, p.Data), nil
+}
+
 // FilePersistence is an implementation of Persistence.
 // It stores conversation states & conversation data in file.
 type FilePersistence struct {
@@ -239,3 +310,11 @@ func (p *FilePersistence) SetData(pk PersistenceKey, data Data) {
 	content.Data[pk] = data
 	p.writeContent(content)
 }
+
+// Keys returns all conversation keys stored in file
+func (p *FilePersistence) Keys() ([]PersistenceKey, error) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	content := p.readContent()
+	return mergeKeys(content.States, content.Data), nil
+}
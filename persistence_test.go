@@ -49,4 +49,7 @@ func TestFilePersistence(t *testing.T) {
 	p.SetState(pk2, "state2")
 	assert(p.GetState(pk1) == "", t)
 	assert(p.GetState(pk2) == "state2", t)
+
+	keys, err := p.Keys()
+	assert(err == nil && reflect.DeepEqual(keys, []tm.PersistenceKey{pk2, pk1}), t, keys, err)
 }
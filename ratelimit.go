@@ -0,0 +1,246 @@
+package telemux
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"sync"
+	"time"
+)
+
+// RateLimitKeyFunc returns a key of the bucket which update is counted against, e. g. ID of the user.
+// Updates with empty key are not limited.
+type RateLimitKeyFunc func(u *Update) string
+
+// ByUser counts updates of each user separately, regardless of chat.
+func ByUser() RateLimitKeyFunc {
+	return func(u *Update) string {
+		if user := u.EffectiveUser(); user != nil {
+			return fmt.Sprintf("user:%d", user.ID)
+		}
+		return ""
+	}
+}
+
+// ByChat counts updates of each chat separately, regardless of user.
+func ByChat() RateLimitKeyFunc {
+	return func(u *Update) string {
+		if chat := u.EffectiveChat(); chat != nil {
+			return fmt.Sprintf("chat:%d", chat.ID)
+		}
+		return ""
+	}
+}
+
+// TokenBucket is a state of a single rate limit bucket.
+type TokenBucket struct {
+	Tokens  float64
+	Updated time.Time
+	// Notified is set when user was told about exceeding the limit (see RateLimiter.ReplyOnce)
+	Notified bool
+	// Full is the time when bucket is refilled completely. After that, store may drop the bucket,
+	// since a missing bucket is the same as a full one.
+	Full time.Time
+}
+
+// RateLimitStore keeps token buckets. Keys are prefixed with name of the limiter, so a store can be shared by many limiters.
+type RateLimitStore interface {
+	GetBucket(key string) (TokenBucket, bool)
+	SetBucket(key string, bucket TokenBucket)
+}
+
+// localRateLimitSweepSize is a number of buckets in LocalRateLimitStore which triggers removal of full buckets.
+const localRateLimitSweepSize = 64
+
+// LocalRateLimitStore keeps token buckets in memory.
+// Buckets which are full again are removed as the store grows, so it is not exhausted by updates of many different users.
+type LocalRateLimitStore struct {
+	mutex   sync.RWMutex
+	buckets map[string]TokenBucket
+	// kept is a number of buckets which were kept by the last sweep
+	kept int
+}
+
+// NewLocalRateLimitStore creates new instance of LocalRateLimitStore.
+func NewLocalRateLimitStore() *LocalRateLimitStore {
+	return &LocalRateLimitStore{buckets: make(map[string]TokenBucket)}
+}
+
+// GetBucket retrieves bucket.
+func (s *LocalRateLimitStore) GetBucket(key string) (TokenBucket, bool) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	bucket, ok := s.buckets[key]
+	return bucket, ok
+}
+
+// SetBucket stores bucket. Once the number of buckets doubles, buckets which are full at bucket.Updated are removed.
+func (s *LocalRateLimitStore) SetBucket(key string, bucket TokenBucket) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.buckets[key] = bucket
+	if len(s.buckets) >= localRateLimitSweepSize && len(s.buckets) >= 2*s.kept {
+		for k, b := range s.buckets {
+			if !b.Full.IsZero() && !b.Full.After(bucket.Updated) {
+				delete(s.buckets, k)
+			}
+		}
+		s.kept = len(s.buckets)
+	}
+}
+
+// Len returns number of stored buckets.
+func (s *LocalRateLimitStore) Len() int {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	return len(s.buckets)
+}
+
+// RateLimiter limits rate of updates using token buckets.
+//
+// Every key (see RateLimitKeyFunc) has a bucket of Burst tokens which is refilled at rate of Burst tokens per Period.
+// Each update takes a token, updates which find the bucket empty exceed the limit.
+// Such updates are dropped silently unless ReplyOnce or RouteTo is used.
+//
+// Limiter can be used either as a filter of a single handler (see Filter) or as a middleware of a multiplexer
+// (see Mux.SetRateLimiter). An update is counted at most once by each limiter.
+type RateLimiter struct {
+	Name   string
+	Burst  int
+	Period time.Duration
+	Key    RateLimitKeyFunc
+	Store  RateLimitStore
+	// Reply is sent when the limit is exceeded, once until the bucket is refilled
+	Reply string
+	// Handler processes updates which exceed the limit
+	Handler *Handler
+
+	mutex sync.Mutex
+}
+
+// NewRateLimiter creates a limiter which allows burst updates per period for each key, e. g.
+//
+//	limiter := tm.NewRateLimiter("search", 5, time.Minute, tm.ByUser())
+//
+// name must be unique among limiters which share the same store. Buckets are kept in LocalRateLimitStore,
+// use SetStore to change this. Panics if burst or period is not positive.
+func NewRateLimiter(name string, burst int, period time.Duration, key RateLimitKeyFunc) *RateLimiter {
+	if burst <= 0 {
+		panic(fmt.Sprintf("invalid burst %d of rate limiter %q", burst, name))
+	}
+	if period <= 0 {
+		panic(fmt.Sprintf("invalid period %s of rate limiter %q", period, name))
+	}
+	return &RateLimiter{
+		Name:   name,
+		Burst:  burst,
+		Period: period,
+		Key:    key,
+		Store:  NewLocalRateLimitStore(),
+	}
+}
+
+// SetStore sets store for token buckets, e. g. a persistent one which survives restarts.
+// This function returns the receiver for convenient chaining.
+func (l *RateLimiter) SetStore(store RateLimitStore) *RateLimiter {
+	l.Store = store
+	return l
+}
+
+// ReplyOnce makes limiter reply with text when the limit is exceeded.
+// The reply is sent only once until the user is allowed to send updates again.
+// This function returns the receiver for convenient chaining.
+func (l *RateLimiter) ReplyOnce(text string) *RateLimiter {
+	l.Reply = text
+	return l
+}
+
+// RouteTo makes limiter pass updates which exceed the limit to handler.
+// u.Context["retry_after"] is set to time.Duration after which the next update will be allowed.
+// This function returns the receiver for convenient chaining.
+func (l *RateLimiter) RouteTo(handler *Handler) *RateLimiter {
+	l.Handler = handler
+	return l
+}
+
+// take takes a token from the bucket of a key. Returns false & time after which a token will be available
+// if the bucket is empty, and whether the user should be notified about that.
+func (l *RateLimiter) take(key string, now time.Time) (allowed bool, retryAfter time.Duration, notify bool) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	key = l.Name + ":" + key
+	rate := float64(l.Burst) / l.Period.Seconds()
+	bucket, ok := l.Store.GetBucket(key)
+	if !ok {
+		bucket = TokenBucket{Tokens: float64(l.Burst)}
+	} else if elapsed := now.Sub(bucket.Updated).Seconds(); elapsed > 0 {
+		bucket.Tokens = math.Min(float64(l.Burst), bucket.Tokens+elapsed*rate)
+	}
+	bucket.Updated = now
+	if bucket.Tokens >= 1 {
+		bucket.Tokens--
+		bucket.Notified = false
+		allowed = true
+	} else {
+		retryAfter = time.Duration((1 - bucket.Tokens) / rate * float64(time.Second))
+		notify = !bucket.Notified
+		bucket.Notified = true
+	}
+	bucket.Full = now.Add(time.Duration((float64(l.Burst) - bucket.Tokens) / rate * float64(time.Second)))
+	l.Store.SetBucket(key, bucket)
+	return
+}
+
+// Allow takes a token for the update and checks if the update is within the limit.
+// Repeated calls with the same update return the same result without taking more tokens.
+func (l *RateLimiter) Allow(u *Update) bool {
+	if allowed, ok := u.rateLimits[l]; ok {
+		return allowed
+	}
+	key := l.Key(u)
+	if key == "" {
+		return true
+	}
+	allowed, retryAfter, notify := l.take(key, time.Now())
+	if u.rateLimits == nil {
+		u.rateLimits = make(map[*RateLimiter]bool)
+	}
+	u.rateLimits[l] = allowed
+	if !allowed {
+		u.setContext("retry_after", retryAfter)
+		if notify && l.Reply != "" {
+			if _, err := u.Reply(l.Reply); err != nil {
+				log.Printf("Failed to notify about exceeded rate limit %s: %s", l.Name, err)
+			}
+		}
+	}
+	return allowed
+}
+
+// limit handles update which exceeds the limit.
+func (l *RateLimiter) limit(u *Update) {
+	if l.Handler != nil && !u.Consumed {
+		l.Handler.Process(u)
+	}
+	u.Consume()
+}
+
+// Filter creates a filter which limits rate of updates handled by a handler, e. g.
+//
+//	tm.NewCommandHandler("search", limiter.Filter(), func(u *tm.Update) { /* ... */ })
+//
+// Since only updates which reach the filter are counted, it should be the last one when combined with other filters.
+// If the limit is exceeded, the update is consumed, so handle functions are not called.
+// The filter still passes in this case, so the update is not processed by other handlers.
+// When NewHelpHandler checks which commands are available, the filter always passes.
+func (l *RateLimiter) Filter() FilterFunc {
+	return func(u *Update) bool {
+		if u.probing {
+			return true
+		}
+		if !l.Allow(u) {
+			l.limit(u)
+		}
+		return true
+	}
+}
@@ -0,0 +1,142 @@
+package telemux_test
+
+import (
+	"testing"
+	"time"
+
+	tm "github.com/and3rson/telemux/v2"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+func newRateLimitUpdate(userID int64, chatID int64, text string) tgbotapi.Update {
+	u := tgbotapi.Update{}
+	u.Message = &tgbotapi.Message{
+		MessageID: 1,
+		Text:      text,
+		From:      &tgbotapi.User{ID: userID},
+		Chat:      &tgbotapi.Chat{ID: chatID},
+	}
+	return u
+}
+
+func TestRateLimiterFilter(t *testing.T) {
+	bot, client := newFakeBot(t)
+	searches := 0
+	fallbacks := 0
+	limiter := tm.NewRateLimiter("search", 2, 100*time.Millisecond, tm.ByUser()).ReplyOnce("Slow down!")
+	mux := tm.NewMux().
+		AddHandler(tm.NewCommandHandler("search", limiter.Filter(), func(u *tm.Update) {
+			searches++
+		})).
+		AddHandler(tm.NewHandler(nil, func(u *tm.Update) {
+			fallbacks++
+		}))
+
+	assert(mux.Dispatch(bot, newRateLimitUpdate(1, 1, "/search foo")), t)
+	assert(mux.Dispatch(bot, newRateLimitUpdate(1, 2, "/search bar")), t)
+	assert(searches == 2, t, searches)
+	assert(len(client.Requests) == 0, t, client.Requests)
+
+	// Limit is exceeded: user is notified only once
+	assert(mux.Dispatch(bot, newRateLimitUpdate(1, 1, "/search baz")), t)
+	assert(mux.Dispatch(bot, newRateLimitUpdate(1, 1, "/search baz")), t)
+	assert(searches == 2, t, searches)
+	assert(fallbacks == 0, t, fallbacks)
+	assert(len(client.Requests) == 1, t, client.Requests)
+	assert(client.Requests[0].Method == "sendMessage", t, client.Requests[0])
+	assert(client.Requests[0].Params.Get("text") == "Slow down!", t, client.Requests[0])
+
+	// Other users & updates which do not reach the filter are not limited
+	assert(mux.Dispatch(bot, newRateLimitUpdate(2, 1, "/search foo")), t)
+	assert(searches == 3, t, searches)
+	assert(mux.Dispatch(bot, newRateLimitUpdate(1, 1, "hello")), t)
+	assert(fallbacks == 1, t, fallbacks)
+
+	// Bucket is refilled
+	time.Sleep(60 * time.Millisecond)
+	assert(mux.Dispatch(bot, newRateLimitUpdate(1, 1, "/search foo")), t)
+	assert(searches == 4, t, searches)
+	assert(mux.Dispatch(bot, newRateLimitUpdate(1, 1, "/search foo")), t)
+	assert(searches == 4, t, searches)
+	assert(len(client.Requests) == 2, t, client.Requests)
+
+	for _, invalid := range []func(){
+		func() { tm.NewRateLimiter("zero", 0, time.Minute, tm.ByUser()) },
+		func() { tm.NewRateLimiter("negative", 5, -time.Minute, tm.ByUser()) },
+	} {
+		func() {
+			defer func() { assert(recover() != nil, t, "Expected panic") }()
+			invalid()
+		}()
+	}
+}
+
+func TestRateLimiterMux(t *testing.T) {
+	var retryAfter time.Duration
+	handled := 0
+	limited := 0
+	limiter := tm.NewRateLimiter("chat", 1, time.Minute, tm.ByChat()).
+		RouteTo(tm.NewHandler(nil, func(u *tm.Update) {
+			limited++
+			retryAfter = u.Context["retry_after"].(time.Duration)
+		}))
+	mux := tm.NewMux().AddMux(
+		tm.NewMux().
+			SetRateLimiter(limiter).
+			AddHandler(tm.NewHandler(tm.HasText(), func(u *tm.Update) {
+				handled++
+			})),
+	)
+
+	assert(mux.Dispatch(nil, newRateLimitUpdate(1, 1, "hello")), t)
+	assert(mux.Dispatch(nil, newRateLimitUpdate(2, 1, "hello")), t)
+	assert(handled == 1, t, handled)
+	assert(limited == 1, t, limited)
+	assert(retryAfter > 59*time.Second && retryAfter <= time.Minute, t, retryAfter)
+
+	// Unhandled updates are counted too
+	assert(!mux.Dispatch(nil, newRateLimitUpdate(1, 2, "/start")), t)
+	assert(mux.Dispatch(nil, newRateLimitUpdate(1, 2, "hello")), t)
+	assert(handled == 1, t, handled)
+	assert(limited == 2, t, limited)
+}
+
+func TestRateLimiterStore(t *testing.T) {
+	store := tm.NewLocalRateLimitStore()
+	first := tm.NewRateLimiter("first", 1, time.Minute, tm.ByUser()).SetStore(store)
+	second := tm.NewRateLimiter("second", 1, time.Minute, tm.ByUser()).SetStore(store)
+
+	u := &tm.Update{Update: newRateLimitUpdate(1, 1, "hello")}
+	assert(first.Allow(u), t)
+	assert(first.Allow(u), t) // Same update is counted once
+	assert(second.Allow(u), t)
+	bucket, ok := store.GetBucket("first:user:1")
+	assert(ok && bucket.Tokens == 0, t, bucket)
+
+	u = &tm.Update{Update: newRateLimitUpdate(1, 1, "hello")}
+	assert(!first.Allow(u), t)
+	assert(!second.Allow(u), t)
+	bucket, _ = store.GetBucket("first:user:1")
+	assert(bucket.Notified, t, bucket)
+
+	// Limiter which shares the store starts with the same state
+	restarted := tm.NewRateLimiter("first", 1, time.Minute, tm.ByUser()).SetStore(store)
+	assert(!restarted.Allow(&tm.Update{Update: newRateLimitUpdate(1, 1, "hello")}), t)
+	assert(restarted.Allow(&tm.Update{Update: newRateLimitUpdate(2, 1, "hello")}), t)
+
+	// Full buckets are removed, so the store does not grow with every user ever seen
+	store = tm.NewLocalRateLimitStore()
+	limiter := tm.NewRateLimiter("flood", 1, 10*time.Millisecond, tm.ByUser()).SetStore(store)
+	for id := int64(1); id <= 100; id++ {
+		assert(limiter.Allow(&tm.Update{Update: newRateLimitUpdate(id, 1, "hello")}), t)
+	}
+	assert(store.Len() == 100, t, store.Len())
+	time.Sleep(20 * time.Millisecond)
+	for id := int64(101); id <= 200; id++ {
+		assert(limiter.Allow(&tm.Update{Update: newRateLimitUpdate(id, 1, "hello")}), t)
+	}
+	assert(store.Len() <= 100, t, store.Len())
+	_, ok = store.GetBucket("flood:user:1")
+	assert(!ok, t)
+	assert(limiter.Allow(&tm.Update{Update: newRateLimitUpdate(1, 1, "hello")}), t)
+}
@@ -0,0 +1,103 @@
+package telemux
+
+import (
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// messageFilter creates a filter which checks effective message of an update.
+// Callback queries are not matched, since their messages are sent by the bot.
+func messageFilter(check func(u *Update, message *tgbotapi.Message) bool) FilterFunc {
+	return func(u *Update) bool {
+		message := u.EffectiveMessage()
+		return u.CallbackQuery == nil && message != nil && check(u, message)
+	}
+}
+
+// IsReplyTo filters updates with message which is a reply to another message.
+// It also populates u.Context["reply_to"] with the original message.
+func IsReplyTo() FilterFunc {
+	return messageFilter(func(u *Update, message *tgbotapi.Message) bool {
+		if message.ReplyToMessage == nil {
+			return false
+		}
+		u.setContext("reply_to", message.ReplyToMessage)
+		return true
+	})
+}
+
+// IsReplyToBot filters updates with message which is a reply to a message sent by the bot.
+// It also populates u.Context["reply_to"] with the original message.
+func IsReplyToBot() FilterFunc {
+	return And(IsReplyTo(), func(u *Update) bool {
+		from := u.EffectiveMessage().ReplyToMessage.From
+		return u.Bot != nil && from != nil && from.ID == u.Bot.Self.ID
+	})
+}
+
+// IsReplyToMessage filters updates with message which is a reply to a specific message.
+// It also populates u.Context["reply_to"] with the original message.
+func IsReplyToMessage(messageID int) FilterFunc {
+	return And(IsReplyTo(), func(u *Update) bool {
+		return u.EffectiveMessage().ReplyToMessage.MessageID == messageID
+	})
+}
+
+// IsForwarded filters updates with forwarded message.
+// It also populates u.Context["forward_from"] with original sender (*tgbotapi.User, nil if sender is hidden or message is from channel)
+// and u.Context["forward_from_chat"] with original chat (*tgbotapi.Chat, nil if message is not from channel).
+func IsForwarded() FilterFunc {
+	return messageFilter(func(u *Update, message *tgbotapi.Message) bool {
+		if message.ForwardDate == 0 {
+			return false
+		}
+		u.setContext("forward_from", message.ForwardFrom)
+		u.setContext("forward_from_chat", message.ForwardFromChat)
+		return true
+	})
+}
+
+// IsForwardedFromChannel filters updates with message forwarded from a specific channel.
+// Zero channelID matches any channel.
+// It also populates u.Context["forward_from_chat"] with the original channel.
+func IsForwardedFromChannel(channelID int64) FilterFunc {
+	return And(IsForwarded(), func(u *Update) bool {
+		chat := u.EffectiveMessage().ForwardFromChat
+		return chat != nil && chat.IsChannel() && (channelID == 0 || chat.ID == channelID)
+	})
+}
+
+// IsViaBot filters updates with message sent via an inline bot.
+// It also populates u.Context["via_bot"] with the inline bot user.
+func IsViaBot() FilterFunc {
+	return messageFilter(func(u *Update, message *tgbotapi.Message) bool {
+		if message.ViaBot == nil {
+			return false
+		}
+		u.setContext("via_bot", message.ViaBot)
+		return true
+	})
+}
+
+// IsAutomaticForward filters updates with channel post which was automatically forwarded to the linked discussion group.
+// It also populates u.Context["forward_from_chat"] with the original channel.
+func IsAutomaticForward() FilterFunc {
+	return messageFilter(func(u *Update, message *tgbotapi.Message) bool {
+		if !message.IsAutomaticForward {
+			return false
+		}
+		u.setContext("forward_from_chat", message.ForwardFromChat)
+		return true
+	})
+}
+
+// HasMediaGroup filters updates with message which is a part of media group (album).
+// It also populates u.Context["media_group_id"] with media group ID.
+func HasMediaGroup() FilterFunc {
+	return messageFilter(func(u *Update, message *tgbotapi.Message) bool {
+		if message.MediaGroupID == "" {
+			return false
+		}
+		u.setContext("media_group_id", message.MediaGroupID)
+		return true
+	})
+}
@@ -0,0 +1,69 @@
+package telemux_test
+
+import (
+	"testing"
+
+	tm "github.com/and3rson/telemux/v2"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+func TestReplyFilters(t *testing.T) {
+	u := &tm.Update{}
+	u.Bot = &tgbotapi.BotAPI{}
+	u.Bot.Self.ID = 42
+	assert(!tm.IsReplyTo()(u), t)
+	u.Message = &tgbotapi.Message{}
+	assert(!tm.IsReplyTo()(u), t)
+
+	original := &tgbotapi.Message{MessageID: 5, From: &tgbotapi.User{ID: 13}}
+	u.Message.ReplyToMessage = original
+	assert(tm.IsReplyTo()(u), t)
+	assert(u.Context["reply_to"] == original, t)
+	assert(!tm.IsReplyToBot()(u), t)
+	assert(tm.IsReplyToMessage(5)(u), t)
+	assert(!tm.IsReplyToMessage(6)(u), t)
+	original.From.ID = 42
+	assert(tm.IsReplyToBot()(u), t)
+
+	// Message of callback query belongs to the bot
+	u = &tm.Update{}
+	u.CallbackQuery = &tgbotapi.CallbackQuery{Message: &tgbotapi.Message{ReplyToMessage: original}}
+	assert(!tm.IsReplyTo()(u), t)
+	assert(u.Context["reply_to"] == nil, t)
+}
+
+func TestForwardFilters(t *testing.T) {
+	u := &tm.Update{}
+	u.Message = &tgbotapi.Message{}
+	assert(!tm.IsForwarded()(u), t)
+	assert(!tm.IsViaBot()(u), t)
+	assert(!tm.IsAutomaticForward()(u), t)
+	assert(!tm.HasMediaGroup()(u), t)
+
+	sender := &tgbotapi.User{ID: 13}
+	u.Message.ForwardDate = 1
+	u.Message.ForwardFrom = sender
+	assert(tm.IsForwarded()(u), t)
+	assert(u.Context["forward_from"] == sender, t)
+	assert(!tm.IsForwardedFromChannel(0)(u), t)
+
+	channel := &tgbotapi.Chat{ID: -100, Type: "channel"}
+	u.Message.ForwardFrom = nil
+	u.Message.ForwardFromChat = channel
+	assert(tm.IsForwardedFromChannel(0)(u), t)
+	assert(tm.IsForwardedFromChannel(-100)(u), t)
+	assert(!tm.IsForwardedFromChannel(-200)(u), t)
+	assert(u.Context["forward_from_chat"] == channel, t)
+
+	u.Message.IsAutomaticForward = true
+	assert(tm.IsAutomaticForward()(u), t)
+
+	bot := &tgbotapi.User{ID: 7, IsBot: true}
+	u.Message.ViaBot = bot
+	assert(tm.IsViaBot()(u), t)
+	assert(u.Context["via_bot"] == bot, t)
+
+	u.Message.MediaGroupID = "album"
+	assert(tm.HasMediaGroup()(u), t)
+	assert(u.Context["media_group_id"] == "album", t)
+}
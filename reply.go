@@ -0,0 +1,206 @@
+package telemux
+
+import (
+	"encoding/json"
+	"errors"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// Errors returned by reply helpers of Update.
+var (
+	ErrNoBot     = errors.New("update has no bot")
+	ErrNoChat    = errors.New("update has no chat")
+	ErrNoMessage = errors.New("update has no message to edit or delete")
+)
+
+type replyRequest struct {
+	update *Update
+	params tgbotapi.Params
+	err    error
+}
+
+// ReplyOption customizes messages sent with Update.Reply & other reply helpers.
+type ReplyOption func(r *replyRequest)
+
+// Quote makes reply quote the message of the update. The reply is sent even if the original message was deleted.
+// Replies are delivered to the same forum topic as the quoted message.
+func Quote() ReplyOption {
+	return func(r *replyRequest) {
+		if message := r.update.EffectiveMessage(); message != nil && r.update.CallbackQuery == nil {
+			r.params.AddNonZero("reply_to_message_id", message.MessageID)
+			r.params.AddBool("allow_sending_without_reply", true)
+		}
+	}
+}
+
+// InThread sends reply to a specific forum topic.
+// Keep in mind that tgbotapi does not expose topic of incoming messages, so it must be known in advance
+// (alternatively, use Quote to reply in the topic of the original message).
+func InThread(threadID int) ReplyOption {
+	return func(r *replyRequest) {
+		r.params.AddNonZero("message_thread_id", threadID)
+	}
+}
+
+// WithMarkup attaches keyboard to reply, e. g. tgbotapi.InlineKeyboardMarkup or tgbotapi.ReplyKeyboardMarkup.
+// If markup cannot be encoded, the reply is not sent and the error is returned by the reply helper.
+func WithMarkup(markup interface{}) ReplyOption {
+	return func(r *replyRequest) {
+		if err := r.params.AddInterface("reply_markup", markup); err != nil && r.err == nil {
+			r.err = err
+		}
+	}
+}
+
+// WithParseMode sets parse mode of reply text, e. g. tgbotapi.ModeMarkdownV2.
+func WithParseMode(mode string) ReplyOption {
+	return func(r *replyRequest) {
+		r.params["parse_mode"] = mode
+	}
+}
+
+// Silent sends reply without notification.
+func Silent() ReplyOption {
+	return func(r *replyRequest) {
+		r.params.AddBool("disable_notification", true)
+	}
+}
+
+// NoPreview disables link previews in reply.
+func NoPreview() ReplyOption {
+	return func(r *replyRequest) {
+		r.params.AddBool("disable_web_page_preview", true)
+	}
+}
+
+// onlyParams removes params which are not applicable to a request, e. g. ones added by Mux.SetReplyOptions.
+func onlyParams(keys ...string) ReplyOption {
+	return func(r *replyRequest) {
+		allowed := make(map[string]bool)
+		for _, key := range keys {
+			allowed[key] = true
+		}
+		for key := range r.params {
+			if !allowed[key] {
+				delete(r.params, key)
+			}
+		}
+	}
+}
+
+func (u *Update) request(method string, params tgbotapi.Params, opts []ReplyOption, files ...tgbotapi.RequestFile) (*tgbotapi.APIResponse, error) {
+	if u.Bot == nil {
+		return nil, ErrNoBot
+	}
+	r := &replyRequest{update: u, params: params}
+	for _, opt := range u.replyOptions {
+		opt(r)
+	}
+	for _, opt := range opts {
+		opt(r)
+	}
+	if r.err != nil {
+		return nil, r.err
+	}
+	if len(files) == 0 {
+		return u.Bot.MakeRequest(method, params)
+	}
+	return u.Bot.UploadFiles(method, params, files)
+}
+
+func (u *Update) send(method string, params tgbotapi.Params, opts []ReplyOption, files ...tgbotapi.RequestFile) (tgbotapi.Message, error) {
+	var message tgbotapi.Message
+	chat := u.EffectiveChat()
+	if chat == nil {
+		return message, ErrNoChat
+	}
+	params.AddNonZero64("chat_id", chat.ID)
+	resp, err := u.request(method, params, opts, files...)
+	if err != nil {
+		return message, err
+	}
+	// Edits of inline messages return true instead of message
+	if len(resp.Result) > 0 && resp.Result[0] == '{' {
+		err = json.Unmarshal(resp.Result, &message)
+	}
+	return message, err
+}
+
+// Reply sends text message to the chat of this update (see EffectiveChat).
+// Options set with Mux.SetReplyOptions are applied before opts.
+func (u *Update) Reply(text string, opts ...ReplyOption) (tgbotapi.Message, error) {
+	return u.send("sendMessage", tgbotapi.Params{"text": text}, opts)
+}
+
+// ReplyMarkdown sends text message formatted with MarkdownV2.
+func (u *Update) ReplyMarkdown(text string, opts ...ReplyOption) (tgbotapi.Message, error) {
+	return u.Reply(text, append([]ReplyOption{WithParseMode(tgbotapi.ModeMarkdownV2)}, opts...)...)
+}
+
+// ReplyHTML sends text message formatted with HTML.
+func (u *Update) ReplyHTML(text string, opts ...ReplyOption) (tgbotapi.Message, error) {
+	return u.Reply(text, append([]ReplyOption{WithParseMode(tgbotapi.ModeHTML)}, opts...)...)
+}
+
+// ReplyPhoto sends photo with optional caption, e. g. u.ReplyPhoto(tgbotapi.FilePath("cat.jpg"), "Meow").
+func (u *Update) ReplyPhoto(photo tgbotapi.RequestFileData, caption string, opts ...ReplyOption) (tgbotapi.Message, error) {
+	params := tgbotapi.Params{}
+	params.AddNonEmpty("caption", caption)
+	if !photo.NeedsUpload() {
+		params["photo"] = photo.SendData()
+		return u.send("sendPhoto", params, opts)
+	}
+	return u.send("sendPhoto", params, opts, tgbotapi.RequestFile{Name: "photo", Data: photo})
+}
+
+// EditMessage changes text of the message which contains pressed inline keyboard button.
+// Only options which make sense for edits are applied (markup, parse mode & link previews).
+// Returns ErrNoMessage if this update is not a callback query.
+func (u *Update) EditMessage(text string, opts ...ReplyOption) (tgbotapi.Message, error) {
+	if u.CallbackQuery == nil {
+		return tgbotapi.Message{}, ErrNoMessage
+	}
+	params := tgbotapi.Params{"text": text}
+	opts = append(append([]ReplyOption{}, opts...), onlyParams(
+		"chat_id", "message_id", "inline_message_id", "text", "parse_mode", "reply_markup", "disable_web_page_preview",
+	))
+	if u.CallbackQuery.InlineMessageID != "" {
+		params["inline_message_id"] = u.CallbackQuery.InlineMessageID
+		var message tgbotapi.Message
+		_, err := u.request("editMessageText", params, opts)
+		return message, err
+	}
+	if u.CallbackQuery.Message == nil {
+		return tgbotapi.Message{}, ErrNoMessage
+	}
+	params.AddNonZero("message_id", u.CallbackQuery.Message.MessageID)
+	return u.send("editMessageText", params, opts)
+}
+
+// DeleteMessage deletes message of this update (for callback queries, the message which contains pressed button).
+func (u *Update) DeleteMessage() error {
+	message, chat := u.EffectiveMessage(), u.EffectiveChat()
+	if message == nil || chat == nil {
+		return ErrNoMessage
+	}
+	params := tgbotapi.Params{}
+	params.AddNonZero64("chat_id", chat.ID)
+	params.AddNonZero("message_id", message.MessageID)
+	_, err := u.request("deleteMessage", params, []ReplyOption{onlyParams("chat_id", "message_id")})
+	return err
+}
+
+// SendChatAction tells user that something is happening on the bot's side, e. g. tgbotapi.ChatTyping.
+// Only InThread option is meaningful here.
+func (u *Update) SendChatAction(action string, opts ...ReplyOption) error {
+	chat := u.EffectiveChat()
+	if chat == nil {
+		return ErrNoChat
+	}
+	params := tgbotapi.Params{"action": action}
+	params.AddNonZero64("chat_id", chat.ID)
+	opts = append(append([]ReplyOption{}, opts...), onlyParams("chat_id", "action", "message_thread_id"))
+	_, err := u.request("sendChatAction", params, opts)
+	return err
+}
@@ -0,0 +1,100 @@
+package telemux_test
+
+import (
+	"testing"
+
+	tm "github.com/and3rson/telemux/v2"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+func TestReply(t *testing.T) {
+	bot, client := newFakeBot(t)
+	u := &tm.Update{Bot: bot}
+	u.Message = &tgbotapi.Message{MessageID: 7, Chat: &tgbotapi.Chat{ID: 42}}
+
+	message, err := u.Reply("Hello", tm.Quote(), tm.Silent(), tm.InThread(3))
+	assert(err == nil && message.MessageID == 100, t, message, err)
+	request := client.Requests[0]
+	assert(request.Method == "sendMessage", t, request)
+	assert(request.Params.Get("chat_id") == "42" && request.Params.Get("text") == "Hello", t, request)
+	assert(request.Params.Get("reply_to_message_id") == "7", t, request)
+	assert(request.Params.Get("disable_notification") == "true", t, request)
+	assert(request.Params.Get("message_thread_id") == "3", t, request)
+
+	client.Requests = nil
+	_, err = u.ReplyMarkdown("*Hi*", tm.NoPreview())
+	assert(err == nil, t, err)
+	_, err = u.ReplyHTML("<b>Hi</b>", tm.WithMarkup(tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(tgbotapi.NewInlineKeyboardButtonData("A", "a")),
+	)))
+	assert(err == nil, t, err)
+	assert(client.Requests[0].Params.Get("parse_mode") == "MarkdownV2", t, client.Requests[0])
+	assert(client.Requests[0].Params.Get("disable_web_page_preview") == "true", t, client.Requests[0])
+	assert(client.Requests[0].Params.Get("reply_to_message_id") == "", t, client.Requests[0])
+	assert(client.Requests[1].Params.Get("parse_mode") == "HTML", t, client.Requests[1])
+	assert(client.Requests[1].Params.Get("reply_markup") != "", t, client.Requests[1])
+
+	client.Requests = nil
+	_, err = u.ReplyPhoto(tgbotapi.FileID("abc"), "Caption")
+	assert(err == nil, t, err)
+	_, err = u.ReplyPhoto(tgbotapi.FileBytes{Name: "cat.jpg", Bytes: []byte("meow")}, "")
+	assert(err == nil, t, err)
+	assert(client.Requests[0].Params.Get("photo") == "abc" && client.Requests[0].Params.Get("caption") == "Caption", t, client.Requests[0])
+	assert(client.Requests[1].Params.Get("photo") == "<file>" && client.Requests[1].Params.Get("chat_id") == "42", t, client.Requests[1])
+
+	client.Requests = nil
+	assert(u.SendChatAction(tgbotapi.ChatTyping, tm.InThread(3), tm.Silent()) == nil, t)
+	assert(u.DeleteMessage() == nil, t)
+	assert(client.Requests[0].Method == "sendChatAction" && len(client.Requests[0].Params) == 3, t, client.Requests[0])
+	assert(client.Requests[1].Method == "deleteMessage" && client.Requests[1].Params.Get("message_id") == "7", t, client.Requests[1])
+
+	_, err = u.EditMessage("Edited")
+	assert(err == tm.ErrNoMessage, t, err)
+	_, err = (&tm.Update{Bot: bot}).Reply("Hello")
+	assert(err == tm.ErrNoChat, t, err)
+	_, err = (&tm.Update{Update: tgbotapi.Update{Message: u.Message}}).Reply("Hello")
+	assert(err == tm.ErrNoBot, t, err)
+
+	client.Requests = nil
+	_, err = u.Reply("Hello", tm.WithMarkup(make(chan int)))
+	assert(err != nil && len(client.Requests) == 0, t, err, client.Requests)
+}
+
+func TestEditMessage(t *testing.T) {
+	bot, client := newFakeBot(t)
+	u := &tm.Update{Bot: bot}
+	u.CallbackQuery = &tgbotapi.CallbackQuery{Message: &tgbotapi.Message{MessageID: 5, Chat: &tgbotapi.Chat{ID: 42}}}
+	_, err := u.EditMessage("Edited", tm.Quote(), tm.WithParseMode(tgbotapi.ModeHTML))
+	assert(err == nil, t, err)
+	request := client.Requests[0]
+	assert(request.Method == "editMessageText" && request.Params.Get("message_id") == "5", t, request)
+	assert(request.Params.Get("text") == "Edited" && request.Params.Get("parse_mode") == "HTML", t, request)
+
+	client.Requests = nil
+	u.CallbackQuery = &tgbotapi.CallbackQuery{InlineMessageID: "inline"}
+	_, err = u.EditMessage("Edited")
+	assert(err == nil, t, err)
+	assert(client.Requests[0].Params.Get("inline_message_id") == "inline", t, client.Requests[0])
+	assert(client.Requests[0].Params.Get("chat_id") == "", t, client.Requests[0])
+}
+
+func TestMuxReplyOptions(t *testing.T) {
+	bot, client := newFakeBot(t)
+	mux := tm.NewMux().
+		SetReplyOptions(tm.Silent()).
+		AddMux(tm.NewMux().
+			SetReplyOptions(tm.Quote()).
+			AddHandler(tm.NewCommandHandler("quote", nil, func(u *tm.Update) { u.Reply("Quoted") })),
+		).
+		AddHandler(tm.NewCommandHandler("plain", nil, func(u *tm.Update) { u.Reply("Plain") }))
+
+	NewTGUpdate := func(text string) tgbotapi.Update {
+		return tgbotapi.Update{Message: &tgbotapi.Message{MessageID: 9, Text: text, Chat: &tgbotapi.Chat{ID: 1}}}
+	}
+	assert(mux.Dispatch(bot, NewTGUpdate("/quote")), t)
+	assert(mux.Dispatch(bot, NewTGUpdate("/plain")), t)
+	assert(client.Requests[0].Params.Get("reply_to_message_id") == "9", t, client.Requests[0])
+	assert(client.Requests[0].Params.Get("disable_notification") == "true", t, client.Requests[0])
+	assert(client.Requests[1].Params.Get("reply_to_message_id") == "", t, client.Requests[1])
+	assert(client.Requests[1].Params.Get("disable_notification") == "true", t, client.Requests[1])
+}
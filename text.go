@@ -0,0 +1,88 @@
+package telemux
+
+import (
+	"strings"
+
+	"golang.org/x/text/cases"
+	"golang.org/x/text/unicode/norm"
+)
+
+// TextOption modifies behavior of text filters such as TextEquals, TextContains & TextPrefix.
+// These filters do not match callback queries.
+type TextOption func(o *textOptions)
+
+type textOptions struct {
+	caption    bool
+	ignoreCase bool
+	normalize  bool
+}
+
+// MatchCaption tells text filter to also check captions of photos, videos, documents etc.
+func MatchCaption() TextOption {
+	return func(o *textOptions) {
+		o.caption = true
+	}
+}
+
+// IgnoreCase tells text filter to compare strings case-insensitively (using Unicode case folding).
+func IgnoreCase() TextOption {
+	return func(o *textOptions) {
+		o.ignoreCase = true
+	}
+}
+
+// Normalize tells text filter to apply Unicode NFKC normalization before comparing strings,
+// so that e. g. "ﬁ" equals "fi" and composed & decomposed forms of "й" are equal.
+func Normalize() TextOption {
+	return func(o *textOptions) {
+		o.normalize = true
+	}
+}
+
+func newTextOptions(opts []TextOption) *textOptions {
+	o := &textOptions{}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return o
+}
+
+func (o *textOptions) transform(s string) string {
+	if o.normalize {
+		s = norm.NFKC.String(s)
+	}
+	if o.ignoreCase {
+		s = cases.Fold().String(s)
+	}
+	return s
+}
+
+func textFilter(value string, opts []TextOption, match func(text, value string) bool) FilterFunc {
+	o := newTextOptions(opts)
+	value = o.transform(value)
+	return func(u *Update) bool {
+		// Message of callback query is sent by the bot, not by the user
+		if u.CallbackQuery != nil || u.EffectiveMessage() == nil {
+			return false
+		}
+		return match(o.transform(u.EffectiveText(o.caption)), value)
+	}
+}
+
+// TextEquals filters updates with message text equal to value.
+// For example, TextEquals("yes", IgnoreCase(), MatchCaption()) will accept "YES" as well as photo with caption "Yes".
+func TextEquals(value string, opts ...TextOption) FilterFunc {
+	return textFilter(value, opts, func(text, value string) bool {
+		return text == value
+	})
+}
+
+// TextContains filters updates with message text which contains value.
+func TextContains(value string, opts ...TextOption) FilterFunc {
+	return textFilter(value, opts, strings.Contains)
+}
+
+// TextPrefix filters updates with message text which starts with value.
+func TextPrefix(value string, opts ...TextOption) FilterFunc {
+	return textFilter(value, opts, strings.HasPrefix)
+}
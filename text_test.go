@@ -0,0 +1,70 @@
+package telemux_test
+
+import (
+	"reflect"
+	"testing"
+
+	tm "github.com/and3rson/telemux/v2"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+func TestTextFilters(t *testing.T) {
+	u := &tm.Update{}
+	assert(!tm.TextEquals("")(u), t)
+
+	u.Message = &tgbotapi.Message{Text: "Hello World"}
+	assert(tm.TextEquals("Hello World")(u), t)
+	assert(!tm.TextEquals("hello world")(u), t)
+	assert(tm.TextEquals("hello world", tm.IgnoreCase())(u), t)
+	assert(tm.TextContains("o W")(u), t)
+	assert(!tm.TextContains("o w")(u), t)
+	assert(tm.TextContains("O W", tm.IgnoreCase())(u), t)
+	assert(tm.TextPrefix("Hello")(u), t)
+	assert(!tm.TextPrefix("World")(u), t)
+
+	u.Message = &tgbotapi.Message{Caption: "Straße"}
+	assert(!tm.TextEquals("Straße")(u), t)
+	assert(tm.TextEquals("Straße", tm.MatchCaption())(u), t)
+	assert(tm.TextEquals("STRASSE", tm.MatchCaption(), tm.IgnoreCase())(u), t)
+
+	// Decomposed "й" & ligature "ﬁ"
+	u.EditedMessage, u.Message = &tgbotapi.Message{Text: "\ufb01ле\u0438\u0306"}, nil
+	assert(!tm.TextEquals("fiле\u0439")(u), t)
+	assert(tm.TextEquals("fiле\u0439", tm.Normalize())(u), t)
+	assert(tm.TextPrefix("FI", tm.Normalize(), tm.IgnoreCase())(u), t)
+
+	// Message of callback query belongs to the bot
+	u = &tm.Update{}
+	u.CallbackQuery = &tgbotapi.CallbackQuery{Message: &tgbotapi.Message{Text: "yes, delete it?"}}
+	assert(!tm.TextPrefix("yes")(u), t)
+	assert(!tm.TextContains("delete")(u), t)
+	assert(!tm.TextEquals("yes, delete it?")(u), t)
+}
+
+func TestTextRegexHandler(t *testing.T) {
+	h := tm.NewTextRegexHandler(`^/get_(?P<id>\d+)(?:_(?P<kind>\w+))?$`, nil)
+
+	u := &tm.Update{Context: tm.Map{}}
+	u.ChannelPost = &tgbotapi.Message{Text: "/get_42"}
+	assert(h.Process(u), t)
+	assert(reflect.DeepEqual(u.Context["matches"], []string{"/get_42", "42", ""}), t)
+	assert(reflect.DeepEqual(u.Context["groups"], map[string]string{"id": "42", "kind": ""}), t)
+
+	u = &tm.Update{Context: tm.Map{}}
+	u.EditedMessage = &tgbotapi.Message{Caption: "/get_13_photo"}
+	assert(h.Process(u), t)
+	assert(reflect.DeepEqual(u.Context["groups"], map[string]string{"id": "13", "kind": "photo"}), t)
+
+	u = &tm.Update{Context: tm.Map{}}
+	u.CallbackQuery = &tgbotapi.CallbackQuery{Message: &tgbotapi.Message{Text: "/get_42"}}
+	assert(!h.Process(u), t)
+
+	u = &tm.Update{Context: tm.Map{}}
+	u.Message = &tgbotapi.Message{Text: "/get_foo"}
+	assert(!h.Process(u), t)
+
+	u = &tm.Update{Context: tm.Map{}}
+	u.Message = &tgbotapi.Message{Text: "Price: 42 USD"}
+	assert(tm.NewRegexHandler(`(?P<amount>\d+) (?P<currency>[A-Z]{3})`, nil).Process(u), t)
+	assert(reflect.DeepEqual(u.Context["groups"], map[string]string{"amount": "42", "currency": "USD"}), t)
+}
@@ -0,0 +1,140 @@
+package telemux
+
+import (
+	"fmt"
+	"log"
+	"reflect"
+	"runtime"
+	"strings"
+)
+
+// TraceNode describes evaluation of a single filter or processor.
+type TraceNode struct {
+	Name     string
+	Result   bool
+	Children []*TraceNode
+	named    bool
+}
+
+// Trace is a tree of filter & processor evaluations recorded for a single update when Mux debug mode is enabled.
+// It allows to find out which part of the filter tree rejected the update.
+type Trace struct {
+	Nodes  []*TraceNode
+	stack  []*TraceNode
+	paused int
+}
+
+func (t *Trace) push(name string, named bool) *TraceNode {
+	node := &TraceNode{Name: name, named: named}
+	if len(t.stack) > 0 {
+		parent := t.stack[len(t.stack)-1]
+		parent.Children = append(parent.Children, node)
+	} else {
+		t.Nodes = append(t.Nodes, node)
+	}
+	t.stack = append(t.stack, node)
+	return node
+}
+
+func (t *Trace) pop() {
+	t.stack = t.stack[:len(t.stack)-1]
+}
+
+func (t *Trace) write(b *strings.Builder, nodes []*TraceNode, depth int) {
+	for _, node := range nodes {
+		fmt.Fprintf(b, "%s%s: %v\n", strings.Repeat("  ", depth), node.Name, node.Result)
+		t.write(b, node.Children, depth+1)
+	}
+}
+
+// String renders trace as an indented tree, one evaluation per line.
+func (t *Trace) String() string {
+	b := &strings.Builder{}
+	t.write(b, t.Nodes, 0)
+	return b.String()
+}
+
+// LogTrace logs trace of the update with standard logger. It can be passed to Mux.SetTraceFunc.
+func LogTrace(u *Update) {
+	if u.Trace != nil {
+		log.Printf("Trace for update %d:\n%s", u.UpdateID, u.Trace)
+	}
+}
+
+// filterName returns a human-readable name of a filter function, e. g. "IsPrivate".
+func filterName(filter FilterFunc) string {
+	name := runtime.FuncForPC(reflect.ValueOf(filter).Pointer()).Name()
+	name = name[strings.LastIndex(name, "/")+1:]
+	parts := strings.Split(name, ".")
+	for i := len(parts) - 1; i > 0; i-- {
+		if !strings.HasPrefix(parts[i], "func") {
+			return parts[i]
+		}
+	}
+	return name
+}
+
+// traceCall records result of call in trace, if tracing is enabled.
+func (u *Update) traceCall(name string, call func() bool) bool {
+	if u.Trace == nil || u.Trace.paused > 0 {
+		return call()
+	}
+	node := u.Trace.push(name, true)
+	defer u.Trace.pop()
+	node.Result = call()
+	return node.Result
+}
+
+// evalLabeledFilter runs filter and records its result under a label, if tracing is enabled.
+// Label is formatted with fmt.Sprintf only when tracing is enabled.
+func (u *Update) evalLabeledFilter(filter FilterFunc, format string, args ...interface{}) bool {
+	if u.Trace == nil || u.Trace.paused > 0 {
+		return filter(u)
+	}
+	node := u.Trace.push(fmt.Sprintf(format, args...), true)
+	defer u.Trace.pop()
+	node.Result = u.evalFilter(filter)
+	return node.Result
+}
+
+// evalFilter runs filter and records its result in trace, if tracing is enabled.
+func (u *Update) evalFilter(filter FilterFunc) bool {
+	if u.Trace == nil || u.Trace.paused > 0 {
+		return filter(u)
+	}
+	node := u.Trace.push(filterName(filter), false)
+	defer u.Trace.pop()
+	node.Result = filter(u)
+	return node.Result
+}
+
+// nameTrace sets name of the filter which is currently being evaluated, unless it is already named.
+func (u *Update) nameTrace(name string) {
+	if u.Trace == nil || u.Trace.paused > 0 || len(u.Trace.stack) == 0 {
+		return
+	}
+	node := u.Trace.stack[len(u.Trace.stack)-1]
+	if !node.named {
+		node.Name = name
+		node.named = true
+	}
+}
+
+// pauseTrace stops recording trace until returned function is called.
+func (u *Update) pauseTrace() func() {
+	if u.Trace == nil {
+		return func() {}
+	}
+	trace := u.Trace
+	trace.paused++
+	return func() { trace.paused-- }
+}
+
+// Named assigns a name to filter. The name is displayed in trace when Mux debug mode is enabled (see Mux.SetDebug).
+// Named filter behaves exactly like the original one.
+func Named(name string, filter FilterFunc) FilterFunc {
+	return func(u *Update) bool {
+		u.nameTrace(name)
+		return filter(u)
+	}
+}
@@ -0,0 +1,81 @@
+package telemux_test
+
+import (
+	"testing"
+
+	tm "github.com/and3rson/telemux/v2"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+func TestTrace(t *testing.T) {
+	NewTGUpdate := func(text string) tgbotapi.Update {
+		u := tgbotapi.Update{}
+		u.Message = &tgbotapi.Message{Text: text, Chat: &tgbotapi.Chat{Type: "group"}}
+		return u
+	}
+
+	var trace *tm.Trace
+	mux := tm.NewMux().
+		SetDebug(true).
+		SetTraceFunc(func(u *tm.Update) { trace = u.Trace }).
+		AddHandler(tm.NewHandler(
+			tm.And(tm.Named("is_private", tm.IsPrivate()), tm.Named("has_text", tm.HasText())),
+			func(u *tm.Update) {},
+		)).
+		AddMux(tm.NewMux().
+			SetGlobalFilter(tm.Not(tm.Named("is_command", tm.HasRegex("^/")))).
+			AddHandler(tm.NewHandler(
+				tm.Or(
+					tm.Named("has_photo", tm.HasPhoto()),
+					tm.Named("has_hello", tm.And(tm.Named("has_text", tm.HasText()), tm.Named("hello", tm.HasRegex("hello")))),
+				),
+				func(u *tm.Update) {
+					// Filters called by handle functions are not recorded
+					tm.HasText()(u)
+				},
+			)),
+		)
+
+	assert(mux.Dispatch(nil, NewTGUpdate("hello")), t)
+	expected := `#0 *telemux.Handler: false
+  And: false
+    is_private: false
+#1 *telemux.Mux: true
+  GlobalFilter: true
+    Not: true
+      is_command: false
+  #0 *telemux.Handler: true
+    Or: true
+      has_photo: false
+      has_hello: true
+        has_text: true
+        hello: true
+`
+	assert(trace.String() == expected, t, "\n"+trace.String())
+
+	assert(!mux.Dispatch(nil, NewTGUpdate("/start")), t)
+	assert(len(trace.Nodes) == 2, t)
+	assert(trace.Nodes[1].Children[0].Name == "GlobalFilter", t)
+	assert(!trace.Nodes[1].Children[0].Result, t)
+
+	mux.SetDebug(false)
+	trace = nil
+	mux.Dispatch(nil, NewTGUpdate("hello"))
+	assert(trace == nil, t)
+}
+
+func TestConversationTrace(t *testing.T) {
+	u := tgbotapi.Update{}
+	u.Message = &tgbotapi.Message{Text: "/start", From: &tgbotapi.User{ID: 1}, Chat: &tgbotapi.Chat{ID: 1}}
+	var trace *tm.Trace
+	mux := tm.NewMux().
+		SetDebug(true).
+		SetTraceFunc(func(u *tm.Update) { trace = u.Trace }).
+		AddHandler(tm.NewConversationHandler("conv", tm.NewLocalPersistence(), tm.StateMap{
+			"": {tm.NewHandler(tm.Named("is_photo", tm.HasPhoto()))},
+		}, nil))
+	mux.Dispatch(nil, u)
+	state := trace.Nodes[0].Children[0].Children[0]
+	assert(state.Name == `conv[""] #0` && !state.Result, t, "\n"+trace.String())
+	assert(state.Children[0].Name == "is_photo", t, "\n"+trace.String())
+}
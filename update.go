@@ -3,6 +3,8 @@ package telemux
 import (
 	"fmt"
 	"log"
+	"runtime/debug"
+	"sync"
 
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
 )
@@ -14,6 +16,22 @@ type Update struct {
 	Consumed           bool
 	PersistenceContext *PersistenceContext
 	Context            Map
+	DataStore          DataStore
+	Trace              *Trace
+
+	// probing is set for copies of update used to check which commands are available (see NewHelpHandler)
+	probing bool
+	// depth is a number of multiplexers which are currently processing the update
+	depth int
+	// autoAnswer is set if one of multiplexers requires callback query to be answered (see Mux.SetAutoAnswerCallbacks)
+	autoAnswer       bool
+	callbackAnswered bool
+	// replyOptions are default options of reply helpers (see Mux.SetReplyOptions)
+	replyOptions []ReplyOption
+	// rateLimits contains decisions of rate limiters which already counted the update
+	rateLimits map[*RateLimiter]bool
+	// dispatching is read-locked by Mux.Dispatch while the update is processed (see runDeferred)
+	dispatching *sync.RWMutex
 }
 
 // Consume marks update as processed. Used by handler functions to interrupt further processing of the update.
@@ -21,6 +39,74 @@ func (u *Update) Consume() {
 	u.Consumed = true
 }
 
+// AnswerCallback answers callback query of this update with an optional notification (or alert, if showAlert is true).
+// Answered queries are not answered again by multiplexers with auto-answering enabled (see Mux.SetAutoAnswerCallbacks).
+func (u *Update) AnswerCallback(text string, showAlert bool) error {
+	if u.CallbackQuery == nil {
+		return fmt.Errorf("update %d has no callback query", u.UpdateID)
+	}
+	if u.callbackAnswered {
+		return fmt.Errorf("callback query %s is already answered", u.CallbackQuery.ID)
+	}
+	if u.Bot == nil {
+		return fmt.Errorf("update %d has no bot", u.UpdateID)
+	}
+	config := tgbotapi.NewCallback(u.CallbackQuery.ID, text)
+	config.ShowAlert = showAlert
+	if _, err := u.Bot.Request(config); err != nil {
+		return err
+	}
+	u.callbackAnswered = true
+	return nil
+}
+
+// CallbackAnswered checks if callback query of this update was answered with AnswerCallback.
+func (u *Update) CallbackAnswered() bool {
+	return u.callbackAnswered
+}
+
+// setContext stores value in update context, creating the context if necessary.
+func (u *Update) setContext(key string, value interface{}) {
+	if u.Context == nil {
+		u.Context = make(Map)
+	}
+	u.Context[key] = value
+}
+
+// detach copies update for deferred handling in another goroutine, so it does not race with the dispatching one.
+// Trace is dropped since it is still being written (and passed to TraceFunc) by the dispatching goroutine.
+func (u *Update) detach() *Update {
+	copied := *u
+	copied.Trace = nil
+	copied.Context = make(Map)
+	for key, value := range u.Context {
+		copied.Context[key] = value
+	}
+	if u.PersistenceContext != nil {
+		context := *u.PersistenceContext
+		context.NewState = nil
+		copied.PersistenceContext = &context
+	}
+	return &copied
+}
+
+// runDeferred calls handle with detached update outside of dispatching, e. g. from a timer.
+// It holds the lock of multiplexer which dispatched the update, so handle does not run concurrently with Mux.Dispatch
+// and can use u.PersistenceContext even if persistence is not safe for concurrent use (e. g. LocalPersistence).
+// Panics in handle are logged.
+func (u *Update) runDeferred(name string, handle HandleFunc) {
+	if u.dispatching != nil {
+		u.dispatching.Lock()
+		defer u.dispatching.Unlock()
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			log.Printf("Panic in %s handler: %v\n%s", name, r, debug.Stack())
+		}
+	}()
+	handle(u)
+}
+
 // EffectiveUser retrieves user object from update.
 func (u *Update) EffectiveUser() *tgbotapi.User {
 	if u.Message != nil {
@@ -69,6 +155,52 @@ func (u *Update) EffectiveMessage() *tgbotapi.Message {
 	return nil
 }
 
+// UserData returns context for data bound to the effective user of this update.
+// Returns nil if update has no user or no DataStore was registered with Mux.SetDataStore.
+func (u *Update) UserData() *DataContext {
+	user := u.EffectiveUser()
+	if u.DataStore == nil || user == nil {
+		return nil
+	}
+	return &DataContext{u.DataStore, DataKey{UserScope, user.ID}}
+}
+
+// ChatData returns context for data bound to the effective chat of this update.
+// Returns nil if update has no chat or no DataStore was registered with Mux.SetDataStore.
+func (u *Update) ChatData() *DataContext {
+	chat := u.EffectiveChat()
+	if u.DataStore == nil || chat == nil {
+		return nil
+	}
+	return &DataContext{u.DataStore, DataKey{ChatScope, chat.ID}}
+}
+
+// BotData returns context for data shared across all users & chats of the bot.
+// Returns nil if no DataStore was registered with Mux.SetDataStore.
+func (u *Update) BotData() *DataContext {
+	if u.DataStore == nil {
+		return nil
+	}
+	var botID int64
+	if u.Bot != nil {
+		botID = u.Bot.Self.ID
+	}
+	return &DataContext{u.DataStore, DataKey{BotScope, botID}}
+}
+
+// EffectiveText retrieves text of effective message.
+// If caption is true, caption is returned for messages without text (e. g. photos or documents).
+func (u *Update) EffectiveText(caption bool) string {
+	message := u.EffectiveMessage()
+	if message == nil {
+		return ""
+	}
+	if message.Text == "" && caption {
+		return message.Caption
+	}
+	return message.Text
+}
+
 // Fields returns some metadata of this update. Useful for passing this directly into logrus.WithFields() or other loggers.
 func (u *Update) Fields() Map {
 	chatID := ""
@@ -1,11 +1,18 @@
 package telemux_test
 
 import (
+	"bytes"
 	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
 	"path"
 	"reflect"
 	"runtime"
+	"strings"
 	"testing"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
 )
 
 func assert(condition bool, t *testing.T, arg ...interface{}) {
@@ -19,3 +26,55 @@ func assert(condition bool, t *testing.T, arg ...interface{}) {
 func getFunctionName(i interface{}) string {
 	return runtime.FuncForPC(reflect.ValueOf(i).Pointer()).Name()
 }
+
+// fakeRequest is a Bot API request recorded by fakeClient.
+type fakeRequest struct {
+	Method string
+	Params url.Values
+}
+
+// fakeClient pretends to be Bot API server & records requests.
+type fakeClient struct {
+	Requests []fakeRequest
+}
+
+func (c *fakeClient) Do(req *http.Request) (*http.Response, error) {
+	method := path.Base(req.URL.Path)
+	var params url.Values
+	if strings.HasPrefix(req.Header.Get("Content-Type"), "multipart/") {
+		if err := req.ParseMultipartForm(1 << 20); err != nil {
+			return nil, err
+		}
+		params = url.Values(req.MultipartForm.Value)
+		for name := range req.MultipartForm.File {
+			params.Set(name, "<file>")
+		}
+	} else if req.Body != nil {
+		body, _ := ioutil.ReadAll(req.Body)
+		params, _ = url.ParseQuery(string(body))
+	}
+	result := `true`
+	switch {
+	case method == "getMe":
+		result = `{"id": 1, "is_bot": true, "username": "test_bot"}`
+	case strings.HasPrefix(method, "send") || strings.HasPrefix(method, "edit"):
+		result = `{"message_id": 100, "chat": {"id": 1}}`
+		c.Requests = append(c.Requests, fakeRequest{method, params})
+	default:
+		c.Requests = append(c.Requests, fakeRequest{method, params})
+	}
+	return &http.Response{
+		StatusCode: http.StatusOK,
+		Body:       ioutil.NopCloser(bytes.NewBufferString(`{"ok": true, "result": ` + result + `}`)),
+	}, nil
+}
+
+// newFakeBot creates a bot which talks to fakeClient instead of Telegram.
+func newFakeBot(t *testing.T) (*tgbotapi.BotAPI, *fakeClient) {
+	client := &fakeClient{}
+	bot, err := tgbotapi.NewBotAPIWithClient("token", tgbotapi.APIEndpoint, client)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return bot, client
+}
@@ -0,0 +1,104 @@
+package telemux
+
+import (
+	"fmt"
+)
+
+const versionKey = "$version"
+
+// DataMigration converts conversation data from one schema version to the next one.
+type DataMigration func(data Data) Data
+
+// VersionedPersistence is a decorator which stamps schema version into conversation data
+// and lazily migrates stale data when it is loaded.
+//
+// Data which was stored before versioning was enabled is considered to be of version 1.
+// Migrations are registered with AddMigration and are applied one by one, e. g. v1 -> v2 -> v3.
+// Migrated data is written back to inner persistence immediately.
+//
+// If one persistence is shared between conversations with different schemas, wrap it separately for every conversation.
+type VersionedPersistence struct {
+	Inner      ConversationPersistence
+	Version    int
+	Migrations map[int]DataMigration
+}
+
+// NewVersionedPersistence creates new instance of VersionedPersistence with current schema version.
+func NewVersionedPersistence(inner ConversationPersistence, version int) *VersionedPersistence {
+	return &VersionedPersistence{inner, version, make(map[int]DataMigration)}
+}
+
+// AddMigration registers a function which migrates data from version "from" to version "from + 1".
+// This function returns the receiver for convenient chaining.
+func (p *VersionedPersistence) AddMigration(from int, migrate DataMigration) *VersionedPersistence {
+	p.Migrations[from] = migrate
+	return p
+}
+
+func dataVersion(data Data) int {
+	switch version := data[versionKey].(type) {
+	case int:
+		return version
+	case int64:
+		return int(version)
+	case float64:
+		return int(version)
+	}
+	return 1
+}
+
+// GetState returns conversation state from inner persistence
+func (p *VersionedPersistence) GetState(pk PersistenceKey) string {
+	return p.Inner.GetState(pk)
+}
+
+// SetState stores conversation state in inner persistence
+func (p *VersionedPersistence) SetState(pk PersistenceKey, state string) {
+	p.Inner.SetState(pk, state)
+}
+
+// GetData returns conversation data, migrating it to the current version if needed
+func (p *VersionedPersistence) GetData(pk PersistenceKey) Data {
+	stored := p.Inner.GetData(pk)
+	version := dataVersion(stored)
+	data := make(Data, len(stored))
+	for key, value := range stored {
+		if key != versionKey {
+			data[key] = value
+		}
+	}
+	if version > p.Version {
+		panic(fmt.Errorf("%s: data version %d is newer than %d", pk, version, p.Version))
+	}
+	if version == p.Version || len(data) == 0 {
+		return data
+	}
+	for ; version < p.Version; version++ {
+		migrate, ok := p.Migrations[version]
+		if !ok {
+			panic(fmt.Errorf("%s: no migration from version %d", pk, version))
+		}
+		data = migrate(data)
+	}
+	p.SetData(pk, data)
+	return data
+}
+
+// SetData stores conversation data stamped with the current version in inner persistence
+func (p *VersionedPersistence) SetData(pk PersistenceKey, data Data) {
+	stamped := make(Data, len(data)+1)
+	for key, value := range data {
+		stamped[key] = value
+	}
+	stamped[versionKey] = p.Version
+	p.Inner.SetData(pk, stamped)
+}
+
+func (p *VersionedPersistence) inner() ConversationPersistence {
+	return p.Inner
+}
+
+// Keys returns keys of inner persistence. Inner persistence must implement Iterable interface.
+func (p *VersionedPersistence) Keys() ([]PersistenceKey, error) {
+	return innerKeys(p.Inner)
+}
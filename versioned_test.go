@@ -0,0 +1,61 @@
+package telemux_test
+
+import (
+	"io/ioutil"
+	"os"
+	"reflect"
+	"testing"
+
+	tm "github.com/and3rson/telemux/v2"
+)
+
+func TestVersionedPersistence(t *testing.T) {
+	f, err := ioutil.TempFile("", "telemux_versioned")
+	if err != nil {
+		t.Error("Failed to create temporary file")
+	}
+	f.Close()
+	os.Remove(f.Name())
+	defer os.Remove(f.Name())
+
+	inner := tm.NewFilePersistence(f.Name())
+	pk := tm.PersistenceKey{"form", 1, 2}
+	empty := tm.PersistenceKey{"form", 3, 4}
+	// Data stored before versioning was enabled
+	inner.SetData(pk, tm.Data{"name": "Foobar"})
+
+	migrations := 0
+	p := tm.NewVersionedPersistence(inner, 3).
+		AddMigration(1, func(data tm.Data) tm.Data {
+			migrations++
+			data["full_name"] = data["name"]
+			delete(data, "name")
+			return data
+		}).
+		AddMigration(2, func(data tm.Data) tm.Data {
+			migrations++
+			data["age"] = 0
+			return data
+		})
+
+	assert(reflect.DeepEqual(p.GetData(pk), tm.Data{"full_name": "Foobar", "age": 0}), t, p.GetData(pk))
+	assert(reflect.DeepEqual(inner.GetData(pk), tm.Data{"full_name": "Foobar", "age": 0.0, "$version": 3.0}), t, inner.GetData(pk))
+	assert(reflect.DeepEqual(p.GetData(pk), tm.Data{"full_name": "Foobar", "age": 0.0}), t)
+	assert(migrations == 2, t)
+
+	assert(reflect.DeepEqual(p.GetData(empty), tm.Data{}), t)
+	assert(migrations == 2, t)
+	p.SetData(empty, tm.Data{"full_name": "Baz"})
+	assert(reflect.DeepEqual(inner.GetData(empty), tm.Data{"full_name": "Baz", "$version": 3.0}), t)
+
+	func() {
+		defer func() { assert(recover() != nil, t, "Expected panic on missing migration") }()
+		inner.SetData(pk, tm.Data{"foo": "bar", "$version": 0})
+		p.GetData(pk)
+	}()
+	func() {
+		defer func() { assert(recover() != nil, t, "Expected panic on newer version") }()
+		inner.SetData(pk, tm.Data{"foo": "bar", "$version": 4})
+		p.GetData(pk)
+	}()
+}